@@ -5,44 +5,197 @@
 package engi
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
 	"math"
 	"path"
+	"reflect"
+	"strings"
 
 	"engo.io/audio"
 	"github.com/ajhager/webgl"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
 )
 
 type Resource struct {
-	kind string
-	name string
-	url  string
+	kind    string
+	name    string
+	url     string
+	options TextureOptions
 }
 
 type Loader struct {
 	resources []Resource
 	images    map[string]*Texture
+	patches   map[string]*NinePatch
 	jsons     map[string]string
 	sounds    map[string]*Sound
+	texts     map[string]string
+	blobs     map[string][]byte
+	assets    map[string]interface{}
+	decoded   map[decodedKey]reflect.Value
+	refs      map[string]int
+	pending   map[string]int
+	bundles   map[string]*Bundle
+	sources   map[string]Resource
+	effects   map[string][]*Effect
+	watch     *watcher
+	errors    []*ResourceError
+	jobs      []*loadJob
+
+	// FailFast stops a Load at the first resource that fails. The rest are
+	// left unloaded, and onFinish is still called.
+	FailFast bool
+
+	// StrictJson makes DecodeJson reject fields that the value being decoded
+	// into does not have.
+	StrictJson bool
+
+	// FS, if set, is where files are read from. Otherwise the backend reads
+	// them itself, fetching them over http on the web. On desktop it starts
+	// with the working directory mounted.
+	FS *FS
+
+	// OnProgress, if set, is called after each resource is loaded or fails.
+	OnProgress func(Progress)
+}
+
+// ResourceError records why a resource could not be loaded. URL is empty
+// for resources that were not loaded from a file.
+type ResourceError struct {
+	Name string
+	URL  string
+	Err  error
+}
+
+func (e *ResourceError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("engi: loading %q: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("engi: loading %q from %s: %v", e.Name, e.URL, e.Err)
+}
+
+// Progress describes how far a Load has got.
+type Progress struct {
+	// Loaded counts the resources that have finished, successfully or not,
+	// out of Total.
+	Loaded, Total int
+	// Bytes counts the bytes read so far. Resources whose size the backend
+	// does not know, such as images on the web, add nothing.
+	Bytes int64
+	// Name is the resource that just finished.
+	Name string
+}
+
+// Fraction returns the portion of resources finished, from 0 to 1.
+func (p Progress) Fraction() float32 {
+	if p.Total == 0 {
+		return 1
+	}
+	return float32(p.Loaded) / float32(p.Total)
 }
 
 func NewLoader() *Loader {
 	return &Loader{
-		resources: make([]Resource, 1),
+		resources: make([]Resource, 0),
 		images:    make(map[string]*Texture),
+		patches:   make(map[string]*NinePatch),
 		jsons:     make(map[string]string),
 		sounds:    make(map[string]*Sound),
+		texts:     make(map[string]string),
+		blobs:     make(map[string][]byte),
+		assets:    make(map[string]interface{}),
+		decoded:   make(map[decodedKey]reflect.Value),
+		refs:      make(map[string]int),
+		pending:   make(map[string]int),
+		bundles:   make(map[string]*Bundle),
+		effects:   make(map[string][]*Effect),
+		sources:   make(map[string]Resource),
+		FS:        defaultFS(),
+	}
+}
+
+// extensions maps lower case file extensions to the kind of resource Add
+// loads them as.
+var extensions = map[string]string{
+	"png":   "image",
+	"jpg":   "image",
+	"jpeg":  "image",
+	"gif":   "image",
+	"bmp":   "image",
+	"tga":   "image",
+	"webp":  "image",
+	"9.png": "ninepatch",
+	"json":  "json",
+	"wav":   "sound",
+	"ogg":   "sound",
+	"mp3":   "sound",
+	"flac":  "sound",
+	"txt":   "text",
+	"xml":   "xml",
+	"bin":   "binary",
+	"vert":  "shader",
+	"frag":  "shader",
+	"glsl":  "shader",
+}
+
+// RegisterExtension makes Add load files ending in ext, with or without the
+// leading dot, as kind.
+func RegisterExtension(ext, kind string) {
+	extensions[strings.ToLower(strings.TrimPrefix(ext, "."))] = kind
+}
+
+// extension returns the lower case extension of url without the dot,
+// ignoring any query string. Nine-patches have the compound extension 9.png.
+func extension(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	url = strings.ToLower(url)
+	if strings.HasSuffix(url, ".9.png") {
+		return "9.png"
 	}
+	return strings.TrimPrefix(path.Ext(url), ".")
 }
 
-func (l *Loader) Add(name, url string) {
-	kind := path.Ext(url)[1:]
-	l.resources = append(l.resources, Resource{kind, name, url})
+// Add queues the file at url to be loaded under name, as the kind of resource
+// its extension is registered to. Images use the first of opts as their
+// texture options, or DefaultTextureOptions if none is given.
+func (l *Loader) Add(name, url string, opts ...TextureOptions) {
+	l.AddAs(extensions[extension(url)], name, url, opts...)
+}
+
+// AddAs queues the file at url to be loaded under name as kind, which is one
+// of image, ninepatch, json, sound, text, xml, binary or shader, or a kind
+// given to RegisterLoader.
+func (l *Loader) AddAs(kind, name, url string, opts ...TextureOptions) {
+	l.resources = append(l.resources, newResource(kind, name, url, opts))
+}
+
+func newResource(kind, name, url string, opts []TextureOptions) Resource {
+	options := DefaultTextureOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	return Resource{kind, name, url, options}
 }
 
 func (l *Loader) Image(name string) *Texture {
 	return l.images[name]
 }
 
+// NinePatch returns a nine-patch loaded from a .9.png file or as ninepatch.
+func (l *Loader) NinePatch(name string) *NinePatch {
+	return l.patches[name]
+}
+
 func (l *Loader) Json(name string) string {
 	return l.jsons[name]
 }
@@ -51,27 +204,34 @@ func (l *Loader) Sound(name string) *Sound {
 	return l.sounds[name]
 }
 
-func (l *Loader) Load(onFinish func()) {
-	for _, r := range l.resources {
-		switch r.kind {
-		case "png":
-			data, err := loadImage(r)
-			if err == nil {
-				l.images[r.name] = NewTexture(data)
-			}
-		case "json":
-			data, err := loadJson(r)
-			if err == nil {
-				l.jsons[r.name] = data
-			}
-		case "wav":
-			data, err := audio.NewSimplePlayer(r.url)
-			if err == nil {
-				l.sounds[r.name] = &Sound{data}
-			}
-		}
+// Text returns the contents of a text, xml or shader resource.
+func (l *Loader) Text(name string) string {
+	return l.texts[name]
+}
+
+// Bytes returns the contents of a binary resource.
+func (l *Loader) Bytes(name string) []byte {
+	return l.blobs[name]
+}
+
+// Get returns the resource loaded under name, of whatever kind: a *Texture,
+// *NinePatch, *Sound, string, []byte, or the value returned by a LoaderFunc.
+func (l *Loader) Get(name string) interface{} {
+	return l.assets[name]
+}
+
+// Errors returns the resources that failed during the last Load to finish.
+func (l *Loader) Errors() []*ResourceError {
+	return l.errors
+}
+
+// Err returns the first error of the last Load, or nil if every resource
+// loaded.
+func (l *Loader) Err() error {
+	if len(l.errors) == 0 {
+		return nil
 	}
-	onFinish()
+	return l.errors[0]
 }
 
 type Image interface {
@@ -80,15 +240,97 @@ type Image interface {
 	Height() int
 }
 
+// LoadImage returns an image from data, which is the path or url of a file
+// to load through Files.FS, an io.Reader to decode, or an image.Image.
+// Loading a file blocks, so on the web it must be called from a goroutine.
+func LoadImage(data interface{}) (Image, error) {
+	switch data := data.(type) {
+	case string:
+		var fs *FS
+		if Files != nil {
+			fs = Files.FS
+		}
+		value, _, err := read(fs, Resource{kind: "image", url: data})
+		if err != nil {
+			return nil, err
+		}
+		if img, ok := value.(Image); ok {
+			return img, nil
+		}
+		return nil, fmt.Errorf("engi: %s did not load as an image", data)
+	case io.Reader:
+		img, _, err := image.Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		return newImage(nrgba(img)), nil
+	case image.Image:
+		return newImage(nrgba(data)), nil
+	}
+	return nil, fmt.Errorf("engi: cannot load an image from %T", data)
+}
+
+// LoadImage loads an image right away, as the package LoadImage does, and
+// stores it as a texture under name. If a texture is already stored under
+// name, its contents are replaced in place, so existing references to it
+// stay valid.
+func (l *Loader) LoadImage(name string, data interface{}, opts ...TextureOptions) (*Texture, error) {
+	img, err := LoadImage(data)
+	if err != nil {
+		url, _ := data.(string)
+		return nil, &ResourceError{Name: name, URL: url, Err: err}
+	}
+
+	texture, ok := l.assets[name].(*Texture)
+	if ok {
+		options := DefaultTextureOptions
+		if len(opts) > 0 {
+			options = opts[0]
+		}
+		texture.upload(img, options)
+	} else {
+		texture = NewTexture(img, opts...)
+		l.put(name, "image", texture)
+	}
+	l.refs[name]++
+	return texture, nil
+}
+
+// nrgba returns img as an NRGBA image with its bounds at the origin and
+// rows packed tightly, as texture uploads expect.
+func nrgba(img image.Image) *image.NRGBA {
+	if m, ok := img.(*image.NRGBA); ok && m.Rect.Min == (image.Point{}) && m.Stride == 4*m.Rect.Dx() {
+		return m
+	}
+	b := img.Bounds()
+	m := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(m, m.Bounds(), img, b.Min, draw.Src)
+	return m
+}
+
 func LoadShader(vertSrc, fragSrc string) *webgl.Program {
-	vertShader := gl.CreateShader(gl.VERTEX_SHADER)
-	gl.ShaderSource(vertShader, vertSrc)
-	gl.CompileShader(vertShader)
+	program, _ := buildShader(vertSrc, fragSrc)
+	return program
+}
+
+// CompileShader is LoadShader, but returns the compiler's log as an error if
+// either shader fails to compile or the program fails to link.
+func CompileShader(vertSrc, fragSrc string) (*webgl.Program, error) {
+	program, err := buildShader(vertSrc, fragSrc)
+	if err != nil {
+		DeleteShader(program)
+		return nil, err
+	}
+	return program, nil
+}
+
+// buildShader compiles and links a program, returning it even if that
+// fails, along with the first error.
+func buildShader(vertSrc, fragSrc string) (*webgl.Program, error) {
+	vertShader, vertErr := compileStage(gl.VERTEX_SHADER, "vertex", vertSrc)
 	defer gl.DeleteShader(vertShader)
 
-	fragShader := gl.CreateShader(gl.FRAGMENT_SHADER)
-	gl.ShaderSource(fragShader, fragSrc)
-	gl.CompileShader(fragShader)
+	fragShader, fragErr := compileStage(gl.FRAGMENT_SHADER, "fragment", fragSrc)
 	defer gl.DeleteShader(fragShader)
 
 	program := gl.CreateProgram()
@@ -96,7 +338,30 @@ func LoadShader(vertSrc, fragSrc string) *webgl.Program {
 	gl.AttachShader(program, fragShader)
 	gl.LinkProgram(program)
 
-	return program
+	switch {
+	case vertErr != nil:
+		return program, vertErr
+	case fragErr != nil:
+		return program, fragErr
+	case !gl.GetProgramParameterb(program, gl.LINK_STATUS):
+		return program, fmt.Errorf("engi: shader failed to link: %s", gl.GetProgramInfoLog(program))
+	}
+	return program, nil
+}
+
+func compileStage(kind int, stage, src string) (*webgl.Shader, error) {
+	shader := gl.CreateShader(kind)
+	gl.ShaderSource(shader, src)
+	gl.CompileShader(shader)
+	if !gl.GetShaderParameterb(shader, gl.COMPILE_STATUS) {
+		return shader, fmt.Errorf("engi: %s shader failed to compile: %s", stage, gl.GetShaderInfoLog(shader))
+	}
+	return shader, nil
+}
+
+// DeleteShader frees a program created by LoadShader.
+func DeleteShader(program *webgl.Program) {
+	gl.DeleteProgram(program)
 }
 
 type Region struct {
@@ -136,29 +401,229 @@ func (r *Region) View() (float32, float32, float32, float32) {
 	return r.u, r.v, r.u2, r.v2
 }
 
+// Filter selects how a texture is sampled when it is drawn smaller or larger
+// than its native size. The mipmapped filters only apply to minification.
+type Filter int
+
+const (
+	FilterNearest Filter = iota
+	FilterLinear
+	FilterNearestMipmapNearest
+	FilterLinearMipmapNearest
+	FilterNearestMipmapLinear
+	FilterLinearMipmapLinear
+)
+
+var filterNames = []string{
+	"nearest",
+	"linear",
+	"nearest-mipmap-nearest",
+	"linear-mipmap-nearest",
+	"nearest-mipmap-linear",
+	"linear-mipmap-linear",
+}
+
+// UnmarshalText reads a filter by name, such as linear or
+// nearest-mipmap-linear, so options can be given in manifests.
+func (f *Filter) UnmarshalText(text []byte) error {
+	for i, name := range filterNames {
+		if string(text) == name {
+			*f = Filter(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown filter %q", text)
+}
+
+func (f Filter) mipmapped() bool {
+	return f >= FilterNearestMipmapNearest
+}
+
+func (f Filter) gl() int {
+	switch f {
+	case FilterNearest:
+		return gl.NEAREST
+	case FilterNearestMipmapNearest:
+		return gl.NEAREST_MIPMAP_NEAREST
+	case FilterLinearMipmapNearest:
+		return gl.LINEAR_MIPMAP_NEAREST
+	case FilterNearestMipmapLinear:
+		return gl.NEAREST_MIPMAP_LINEAR
+	case FilterLinearMipmapLinear:
+		return gl.LINEAR_MIPMAP_LINEAR
+	}
+	return gl.LINEAR
+}
+
+// Wrap selects what a texture samples outside of the [0, 1] coordinate range.
+type Wrap int
+
+const (
+	WrapClamp Wrap = iota
+	WrapRepeat
+	WrapMirror
+)
+
+var wrapNames = []string{"clamp", "repeat", "mirror"}
+
+// UnmarshalText reads a wrap by name: clamp, repeat or mirror.
+func (w *Wrap) UnmarshalText(text []byte) error {
+	for i, name := range wrapNames {
+		if string(text) == name {
+			*w = Wrap(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown wrap %q", text)
+}
+
+func (w Wrap) gl() int {
+	switch w {
+	case WrapRepeat:
+		return gl.REPEAT
+	case WrapMirror:
+		return gl.MIRRORED_REPEAT
+	}
+	return gl.CLAMP_TO_EDGE
+}
+
+// TextureOptions controls how a texture is sampled. The web backend only
+// supports repeating wraps and mipmaps on textures whose sides are powers of
+// two; other textures fall back to clamping and non-mipmapped filtering there.
+type TextureOptions struct {
+	MinFilter Filter
+	MagFilter Filter
+	WrapS     Wrap
+	WrapT     Wrap
+	Mipmaps   bool
+}
+
+// DefaultTextureOptions is used by NewTexture and Loader.Add when no options
+// are given: smooth minification, crisp magnification and clamped edges.
+var DefaultTextureOptions = TextureOptions{
+	MinFilter: FilterLinear,
+	MagFilter: FilterNearest,
+	WrapS:     WrapClamp,
+	WrapT:     WrapClamp,
+}
+
+// PixelTextureOptions samples with nearest filtering in both directions,
+// which keeps pixel art sharp at any scale.
+var PixelTextureOptions = TextureOptions{
+	MinFilter: FilterNearest,
+	MagFilter: FilterNearest,
+	WrapS:     WrapClamp,
+	WrapT:     WrapClamp,
+}
+
 type Texture struct {
-	id     *webgl.Texture
-	width  int
-	height int
+	id      *webgl.Texture
+	width   int
+	height  int
+	options TextureOptions
 }
 
-func NewTexture(img Image) *Texture {
-	id := gl.CreateTexture()
+// NewTexture uploads img to the GPU. The first of opts, if any, replaces
+// DefaultTextureOptions.
+func NewTexture(img Image, opts ...TextureOptions) *Texture {
+	options := DefaultTextureOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
 
-	gl.BindTexture(gl.TEXTURE_2D, id)
+	texture := &Texture{id: gl.CreateTexture()}
+	texture.upload(img, options)
 
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	return texture
+}
 
+// upload replaces the texture's image and options, keeping its id.
+func (t *Texture) upload(img Image, options TextureOptions) {
 	if img.Data() == nil {
 		panic("Texture image data is nil.")
 	}
 
+	gl.BindTexture(gl.TEXTURE_2D, t.id)
 	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, gl.RGBA, gl.UNSIGNED_BYTE, img.Data())
 
-	return &Texture{id, img.Width(), img.Height()}
+	t.width = img.Width()
+	t.height = img.Height()
+	// The new image has no mipmaps, so SetOptions must generate them again.
+	t.options.Mipmaps = false
+	t.SetOptions(options)
+}
+
+// Options returns the sampling options currently applied to the texture.
+func (t *Texture) Options() TextureOptions {
+	return t.options
+}
+
+// SetOptions changes how the texture is sampled, generating mipmaps if they
+// are requested and the texture supports them.
+func (t *Texture) SetOptions(options TextureOptions) {
+	if npotLimited && !t.powerOfTwo() {
+		options.WrapS = WrapClamp
+		options.WrapT = WrapClamp
+		options.Mipmaps = false
+	}
+
+	if !options.Mipmaps {
+		switch options.MinFilter {
+		case FilterNearestMipmapNearest, FilterNearestMipmapLinear:
+			options.MinFilter = FilterNearest
+		case FilterLinearMipmapNearest, FilterLinearMipmapLinear:
+			options.MinFilter = FilterLinear
+		}
+	}
+
+	if options.MagFilter != FilterNearest {
+		options.MagFilter = FilterLinear
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, t.id)
+
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, options.WrapS.gl())
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, options.WrapT.gl())
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, options.MinFilter.gl())
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, options.MagFilter.gl())
+
+	if options.Mipmaps && !t.options.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
+
+	t.options = options
+}
+
+// SetFilter changes the minification and magnification filters.
+func (t *Texture) SetFilter(min, mag Filter) {
+	options := t.options
+	options.MinFilter = min
+	options.MagFilter = mag
+	options.Mipmaps = options.Mipmaps || min.mipmapped()
+	t.SetOptions(options)
+}
+
+// SetWrap changes how the texture repeats horizontally and vertically.
+func (t *Texture) SetWrap(horizontal, vertical Wrap) {
+	options := t.options
+	options.WrapS = horizontal
+	options.WrapT = vertical
+	t.SetOptions(options)
+}
+
+// GenerateMipmaps rebuilds the texture's mipmap chain.
+func (t *Texture) GenerateMipmaps() {
+	if npotLimited && !t.powerOfTwo() {
+		return
+	}
+	gl.BindTexture(gl.TEXTURE_2D, t.id)
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+	t.options.Mipmaps = true
+}
+
+func (t *Texture) powerOfTwo() bool {
+	return t.width > 0 && t.width&(t.width-1) == 0 &&
+		t.height > 0 && t.height&(t.height-1) == 0
 }
 
 // Width returns the width of the texture.
@@ -179,10 +644,46 @@ func (r *Texture) View() (float32, float32, float32, float32) {
 	return 0.0, 0.0, 1.0, 1.0
 }
 
+// Delete frees the texture's GPU memory. Regions of it must not be drawn
+// afterwards.
+func (t *Texture) Delete() {
+	if t.id != nil {
+		gl.DeleteTexture(t.id)
+		t.id = nil
+	}
+}
+
+// Sound is a loaded WAV, OGG Vorbis, MP3 or FLAC file.
 type Sound struct {
 	*audio.Player
 }
 
+// pcm is a decoded sound, ready for a player to stream.
+type pcm struct {
+	format audio.Format
+	rate   int64
+	data   []byte
+}
+
+// newPlayer opens a player for what read returned for a sound: its decoded
+// samples if it came through an FS, or otherwise its url for the backend to
+// open.
+func newPlayer(value interface{}) (*audio.Player, error) {
+	if p, ok := value.(*pcm); ok {
+		return audio.NewPlayer(soundData{bytes.NewReader(p.data)}, p.format, p.rate)
+	}
+	return audio.NewSimplePlayer(value.(string))
+}
+
+// soundData lets a player stream a sound held in memory.
+type soundData struct {
+	*bytes.Reader
+}
+
+func (soundData) Close() error {
+	return nil
+}
+
 type Point struct {
 	X, Y float32
 }
@@ -195,6 +696,10 @@ type Sprite struct {
 	Color    uint32
 	Alpha    float32
 	Region   *Region
+	Layer    int
+	Depth    float32
+	FlipX    bool
+	FlipY    bool
 }
 
 func NewSprite(region *Region, x, y float32) *Sprite {
@@ -209,8 +714,39 @@ func NewSprite(region *Region, x, y float32) *Sprite {
 	}
 }
 
+// Render draws the sprite with the batch's sort keys set to its Layer and
+// Depth, restoring the previous keys afterwards.
 func (s *Sprite) Render(batch *Batch) {
-	batch.Draw(s.Region, s.Position.X, s.Position.Y, s.Anchor.X, s.Anchor.Y, s.Scale.X, s.Scale.Y, s.Rotation, s.Color, s.Alpha)
+	layer, depth := batch.layer, batch.depth
+	batch.SetDepth(s.Layer, s.Depth)
+	defer batch.SetDepth(layer, depth)
+
+	if !s.FlipX && !s.FlipY {
+		batch.Draw(s.Region, s.Position.X, s.Position.Y, s.Anchor.X, s.Anchor.Y, s.Scale.X, s.Scale.Y, s.Rotation, s.Color, s.Alpha)
+		return
+	}
+	o := DrawOptions{
+		OriginX:  s.Anchor.X,
+		OriginY:  s.Anchor.Y,
+		ScaleX:   s.Scale.X,
+		ScaleY:   s.Scale.Y,
+		Rotation: s.Rotation,
+		FlipX:    s.FlipX,
+		FlipY:    s.FlipY,
+	}
+	o.SetColor(s.Color, s.Alpha)
+	batch.DrawWith(s.Region, s.Position.X, s.Position.Y, &o)
+}
+
+// SetTint sets the sprite's Color and Alpha from c.
+func (s *Sprite) SetTint(c Color) {
+	s.Color = c.Hex()
+	s.Alpha = c.A
+}
+
+// Tint returns the sprite's Color and Alpha as a Color.
+func (s *Sprite) Tint() Color {
+	return Hex(s.Color).WithAlpha(s.Alpha)
 }
 
 func (s *Sprite) Width() float32 {
@@ -225,22 +761,27 @@ var batchVert = `
 attribute vec2 in_Position;
 attribute vec4 in_Color;
 attribute vec2 in_TexCoords;
+attribute float in_TexIndex;
 
 uniform vec2 uf_Projection;
 
 varying vec4 var_Color;
 varying vec2 var_TexCoords;
+varying float var_TexIndex;
 
 const vec2 center = vec2(-1.0, 1.0);
 
 void main() {
   var_Color = in_Color;
   var_TexCoords = in_TexCoords;
+  var_TexIndex = in_TexIndex;
 	gl_Position = vec4(in_Position.x / uf_Projection.x + center.x,
 										 in_Position.y / -uf_Projection.y + center.y,
 										 0.0, 1.0);
 }`
 
+// batchFrag selects one of the batch's texture units per fragment. GLSL ES
+// only allows sampler arrays to be indexed by constants, hence the branches.
 var batchFrag = `
 #ifdef GL_ES
 #define LOWP lowp
@@ -251,9 +792,23 @@ precision mediump float;
 
 varying vec4 var_Color;
 varying vec2 var_TexCoords;
-
-uniform sampler2D uf_Texture;
+varying float var_TexIndex;
+
+uniform sampler2D uf_Textures[8];
+uniform float uf_AlphaTest;
+
+vec4 texel(float index, vec2 uv) {
+  if (index < 0.5) return texture2D(uf_Textures[0], uv);
+  else if (index < 1.5) return texture2D(uf_Textures[1], uv);
+  else if (index < 2.5) return texture2D(uf_Textures[2], uv);
+  else if (index < 3.5) return texture2D(uf_Textures[3], uv);
+  else if (index < 4.5) return texture2D(uf_Textures[4], uv);
+  else if (index < 5.5) return texture2D(uf_Textures[5], uv);
+  else if (index < 6.5) return texture2D(uf_Textures[6], uv);
+  return texture2D(uf_Textures[7], uv);
+}
 
 void main (void) {
-  gl_FragColor = var_Color * texture2D(uf_Texture, var_TexCoords);
+  gl_FragColor = var_Color * texel(var_TexIndex, var_TexCoords);
+  if (gl_FragColor.a < uf_AlphaTest) discard;
 }`
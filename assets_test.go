@@ -0,0 +1,52 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNRGBA(t *testing.T) {
+	full := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	for i := range full.Pix {
+		full.Pix[i] = uint8(i)
+	}
+	gray := image.NewGray(image.Rect(0, 0, 4, 3))
+	gray.SetGray(1, 2, color.Gray{0x80})
+
+	tests := []struct {
+		name string
+		img  image.Image
+		same bool
+	}{
+		{"packed", full, true},
+		{"offset", full.SubImage(image.Rect(1, 1, 3, 3)), false},
+		{"padded rows", full.SubImage(image.Rect(0, 0, 2, 3)), false},
+		{"other model", gray, false},
+	}
+
+	for _, tt := range tests {
+		m := nrgba(tt.img)
+		if same := m == tt.img; same != tt.same {
+			t.Errorf("%s: returned the input is %v, want %v", tt.name, same, tt.same)
+		}
+
+		b := tt.img.Bounds()
+		if m.Rect != image.Rect(0, 0, b.Dx(), b.Dy()) || m.Stride != 4*b.Dx() || len(m.Pix) != 4*b.Dx()*b.Dy() {
+			t.Errorf("%s: got bounds %v, stride %d and %d bytes", tt.name, m.Rect, m.Stride, len(m.Pix))
+			continue
+		}
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				want := color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y))
+				if got := m.NRGBAAt(x, y); got != want {
+					t.Errorf("%s: pixel %d, %d = %v, want %v", tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
@@ -5,13 +5,49 @@
 package engi
 
 import (
+	"fmt"
 	"log"
 	"math"
 
 	"github.com/ajhager/webgl"
 )
 
-const size = 10000
+// maxCapacity is the most quads a batch can hold between flushes while
+// keeping its indices within 16 bits.
+const maxCapacity = 16384
+
+// BufferStrategy selects how a batch uploads vertices to the GPU.
+type BufferStrategy int
+
+const (
+	// BufferSubData updates a single buffer in place.
+	BufferSubData BufferStrategy = iota
+	// BufferOrphan gives the buffer new storage on every flush, so the driver
+	// doesn't wait for draws still reading the old contents.
+	BufferOrphan
+	// BufferRing cycles through several buffers, one per flush.
+	BufferRing
+)
+
+// BatchOptions configures a batch. Capacity is the number of quads held
+// between flushes; smaller batches use less memory and upload less. A zero
+// Capacity uses that of DefaultBatchOptions.
+type BatchOptions struct {
+	Capacity int
+	Strategy BufferStrategy
+	RingSize int
+}
+
+// DefaultBatchOptions is used by NewBatch when no options are given.
+var DefaultBatchOptions = BatchOptions{
+	Capacity: 10000,
+	Strategy: BufferSubData,
+	RingSize: 3,
+}
+
+// textureUnits is how many textures a batch binds at once. WebGL guarantees
+// at least eight fragment texture units.
+const textureUnits = 8
 
 type Drawable interface {
 	Texture() *webgl.Texture
@@ -22,58 +58,110 @@ type Drawable interface {
 
 type Batch struct {
 	drawing      bool
-	lastTexture  *webgl.Texture
+	textures     []*webgl.Texture
 	vertices     []float32
 	vertexVBO    *webgl.Buffer
 	indices      []uint16
 	indexVBO     *webgl.Buffer
-	index        int
+	vertexVBOs   []*webgl.Buffer
+	indexVBOs    []*webgl.Buffer
+	ring         int
+	strategy     BufferStrategy
+	maxVertices  int
+	maxIndices   int
+	vertexCount  int
+	indexCount   int
+	sprites      int
 	shader       *webgl.Program
 	inPosition   int
 	inColor      int
 	inTexCoords  int
+	inTexIndex   int
 	ufProjection *webgl.UniformLocation
+	ufAlphaTest  *webgl.UniformLocation
 	projX        float32
 	projY        float32
+	target       *RenderTarget
+	savedProjX   float32
+	savedProjY   float32
+	sorted       bool
+	layer        int
+	depth        float32
+	deferred     deferredDraws
+	stats        BatchStats
+	clips        []clipRect
+	masking      bool
 }
 
-func NewBatch(width, height float32) *Batch {
+// batchShader is compiled once and shared by every batch.
+var batchShader *webgl.Program
+
+func loadBatchShader() *webgl.Program {
+	if batchShader != nil {
+		return batchShader
+	}
+
+	batchShader = LoadShader(batchVert, batchFrag)
+
+	gl.UseProgram(batchShader)
+	gl.Uniform1f(gl.GetUniformLocation(batchShader, "uf_AlphaTest"), 0)
+	for i := 0; i < textureUnits; i++ {
+		gl.Uniform1i(gl.GetUniformLocation(batchShader, fmt.Sprintf("uf_Textures[%d]", i)), i)
+	}
+
+	return batchShader
+}
+
+// NewBatch creates a batch projecting width by height onto the screen. The
+// first of opts, if any, replaces DefaultBatchOptions.
+func NewBatch(width, height float32, opts ...BatchOptions) *Batch {
+	options := DefaultBatchOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Capacity <= 0 {
+		options.Capacity = DefaultBatchOptions.Capacity
+	}
+	if options.Capacity <= 0 || options.Capacity > maxCapacity {
+		options.Capacity = maxCapacity
+	}
+	if options.Strategy != BufferRing || options.RingSize < 1 {
+		options.RingSize = 1
+	}
+
 	batch := new(Batch)
 
-	batch.shader = LoadShader(batchVert, batchFrag)
+	batch.shader = loadBatchShader()
 	batch.inPosition = gl.GetAttribLocation(batch.shader, "in_Position")
 	batch.inColor = gl.GetAttribLocation(batch.shader, "in_Color")
 	batch.inTexCoords = gl.GetAttribLocation(batch.shader, "in_TexCoords")
+	batch.inTexIndex = gl.GetAttribLocation(batch.shader, "in_TexIndex")
 	batch.ufProjection = gl.GetUniformLocation(batch.shader, "uf_Projection")
+	batch.ufAlphaTest = gl.GetUniformLocation(batch.shader, "uf_AlphaTest")
 
-	batch.vertices = make([]float32, 20*size)
-	batch.indices = make([]uint16, 6*size)
+	batch.strategy = options.Strategy
+	batch.maxVertices = options.Capacity * 4
+	batch.maxIndices = options.Capacity * 6
 
-	for i, j := 0, 0; i < size*6; i, j = i+6, j+4 {
-		batch.indices[i+0] = uint16(j + 0)
-		batch.indices[i+1] = uint16(j + 1)
-		batch.indices[i+2] = uint16(j + 2)
-		batch.indices[i+3] = uint16(j + 0)
-		batch.indices[i+4] = uint16(j + 2)
-		batch.indices[i+5] = uint16(j + 3)
-	}
-
-	batch.indexVBO = gl.CreateBuffer()
-	batch.vertexVBO = gl.CreateBuffer()
+	batch.textures = make([]*webgl.Texture, 0, textureUnits)
+	batch.vertices = make([]float32, 6*batch.maxVertices)
+	batch.indices = make([]uint16, batch.maxIndices)
 
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, batch.indexVBO)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, batch.indices, gl.STATIC_DRAW)
+	for i := 0; i < options.RingSize; i++ {
+		indexVBO := gl.CreateBuffer()
+		vertexVBO := gl.CreateBuffer()
 
-	gl.BindBuffer(gl.ARRAY_BUFFER, batch.vertexVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, batch.vertices, gl.DYNAMIC_DRAW)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, indexVBO)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, batch.indices, gl.DYNAMIC_DRAW)
 
-	gl.EnableVertexAttribArray(batch.inPosition)
-	gl.EnableVertexAttribArray(batch.inTexCoords)
-	gl.EnableVertexAttribArray(batch.inColor)
+		gl.BindBuffer(gl.ARRAY_BUFFER, vertexVBO)
+		gl.BufferData(gl.ARRAY_BUFFER, batch.vertices, gl.DYNAMIC_DRAW)
 
-	gl.VertexAttribPointer(batch.inPosition, 2, gl.FLOAT, false, 20, 0)
-	gl.VertexAttribPointer(batch.inTexCoords, 2, gl.FLOAT, false, 20, 8)
-	gl.VertexAttribPointer(batch.inColor, 4, gl.UNSIGNED_BYTE, true, 20, 16)
+		batch.indexVBOs = append(batch.indexVBOs, indexVBO)
+		batch.vertexVBOs = append(batch.vertexVBOs, vertexVBO)
+	}
+	batch.indexVBO = batch.indexVBOs[0]
+	batch.vertexVBO = batch.vertexVBOs[0]
 
 	batch.projX = width / 2
 	batch.projY = height / 2
@@ -89,53 +177,131 @@ func (b *Batch) Begin() {
 		log.Fatal("Batch.End() must be called first")
 	}
 	b.drawing = true
+	b.layer = 0
+	b.depth = 0
+	b.stats = BatchStats{}
 	gl.UseProgram(b.shader)
+	b.bind()
+	b.applyClip()
+}
+
+// bind restores the buffers and attribute layout of the batch, which other
+// batches and post-processing passes share.
+func (b *Batch) bind() {
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, b.indexVBO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.vertexVBO)
+
+	gl.EnableVertexAttribArray(b.inPosition)
+	gl.EnableVertexAttribArray(b.inTexCoords)
+	gl.EnableVertexAttribArray(b.inColor)
+	gl.EnableVertexAttribArray(b.inTexIndex)
+
+	gl.VertexAttribPointer(b.inPosition, 2, gl.FLOAT, false, 24, 0)
+	gl.VertexAttribPointer(b.inTexCoords, 2, gl.FLOAT, false, 24, 8)
+	gl.VertexAttribPointer(b.inColor, 4, gl.UNSIGNED_BYTE, true, 24, 16)
+	gl.VertexAttribPointer(b.inTexIndex, 1, gl.FLOAT, false, 24, 20)
+}
+
+// BeginTarget begins drawing into target, using a projection that matches its
+// size. The target is unbound and the projection restored by End.
+func (b *Batch) BeginTarget(target *RenderTarget) {
+	b.Begin()
+	target.Begin()
+	b.target = target
+	b.savedProjX, b.savedProjY = b.projX, b.projY
+	b.SetProjection(target.Width(), target.Height())
+}
+
+// Delete frees the batch's vertex and index buffers. The batch must not be
+// used afterwards.
+func (b *Batch) Delete() {
+	if b.drawing {
+		log.Fatal("Batch.Delete() called between Begin and End")
+	}
+	for i := range b.vertexVBOs {
+		gl.DeleteBuffer(b.vertexVBOs[i])
+		gl.DeleteBuffer(b.indexVBOs[i])
+	}
+	b.vertexVBOs, b.indexVBOs = nil, nil
+	b.vertexVBO, b.indexVBO = nil, nil
 }
 
 func (b *Batch) End() {
 	if !b.drawing {
 		log.Fatal("Batch.Begin() must be called first")
 	}
-	if b.index > 0 {
-		b.flush()
+	if len(b.deferred.draws) > 0 {
+		b.drawDeferred()
+	}
+	if len(b.clips) > 0 {
+		log.Fatalf("Batch.End() called with %d clips still pushed", len(b.clips))
 	}
+	b.flush(flushEnd)
+	b.disableMask()
 	b.drawing = false
 
-	b.lastTexture = nil
+	if b.target != nil {
+		b.target.End()
+		b.target = nil
+		b.projX, b.projY = b.savedProjX, b.savedProjY
+	}
 }
 
-func (b *Batch) flush() {
-	if b.lastTexture == nil {
+func (b *Batch) flush(reason flushReason) {
+	if b.indexCount == 0 {
 		return
 	}
 
-	gl.BindTexture(gl.TEXTURE_2D, b.lastTexture)
+	b.stats.count(reason, b.sprites, b.vertexCount)
+	frameStats.count(reason, b.sprites, b.vertexCount)
+
+	for i, texture := range b.textures {
+		gl.ActiveTexture(gl.TEXTURE0 + i)
+		gl.BindTexture(gl.TEXTURE_2D, texture)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
 
 	gl.Uniform2f(b.ufProjection, b.projX, b.projY)
 
-	gl.BufferSubData(gl.ARRAY_BUFFER, 0, b.vertices)
-	gl.DrawElements(gl.TRIANGLES, 6*b.index, gl.UNSIGNED_SHORT, 0)
+	b.upload()
+	gl.DrawElements(gl.TRIANGLES, b.indexCount, gl.UNSIGNED_SHORT, 0)
+
+	b.vertexCount = 0
+	b.indexCount = 0
+	b.sprites = 0
+	b.textures = b.textures[:0]
+}
 
-	b.index = 0
+// upload sends the used part of the vertex and index data to the GPU.
+func (b *Batch) upload() {
+	vertices := b.vertices[:b.vertexCount*6]
+	indices := b.indices[:b.indexCount]
+
+	switch b.strategy {
+	case BufferOrphan:
+		gl.BufferData(gl.ARRAY_BUFFER, vertices, gl.STREAM_DRAW)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, indices, gl.STREAM_DRAW)
+	case BufferRing:
+		b.ring = (b.ring + 1) % len(b.vertexVBOs)
+		b.vertexVBO = b.vertexVBOs[b.ring]
+		b.indexVBO = b.indexVBOs[b.ring]
+		b.bind()
+		fallthrough
+	default:
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, vertices)
+		gl.BufferSubData(gl.ELEMENT_ARRAY_BUFFER, 0, indices)
+	}
 }
 
 func (b *Batch) SetProjection(width, height float32) {
+	if b.drawing {
+		b.flush(flushState)
+	}
 	b.projX = width / 2
 	b.projY = height / 2
 }
 
 func (b *Batch) Draw(r Drawable, x, y, originX, originY, scaleX, scaleY, rotation float32, color uint32, transparency float32) {
-	if !b.drawing {
-		log.Fatal("Batch.Begin() must be called first")
-	}
-
-	if r.Texture() != b.lastTexture {
-		if b.lastTexture != nil {
-			b.flush()
-		}
-		b.lastTexture = r.Texture()
-	}
-
 	x -= originX * r.Width()
 	y -= originY * r.Height()
 
@@ -214,43 +380,246 @@ func (b *Batch) Draw(r Drawable, x, y, originX, originY, scaleX, scaleY, rotatio
 	x4 += worldOriginX
 	y4 += worldOriginY
 
-	red := (color >> 16) & 0xFF
-	green := ((color >> 8) & 0xFF) << 8
-	blue := (color & 0xFF) << 16
-	alpha := uint32(transparency*255.0) << 24
-	tint := math.Float32frombits((alpha | blue | green | red) & 0xfeffffff)
+	tint := packColor(color, transparency)
 
-	idx := b.index * 20
+	u, v, u2, v2 := r.View()
+
+	b.quad(r.Texture(), &[20]float32{
+		x1, y1, u, v, tint,
+		x4, y4, u2, v, tint,
+		x3, y3, u2, v2, tint,
+		x2, y2, u, v2, tint,
+	})
+}
+
+// DrawColor is Draw with a Color, which may be translucent.
+func (b *Batch) DrawColor(r Drawable, x, y, originX, originY, scaleX, scaleY, rotation float32, c Color) {
+	b.Draw(r, x, y, originX, originY, scaleX, scaleY, rotation, c.Hex(), c.A)
+}
+
+// DrawOptions describes a draw in more detail than the arguments of Draw.
+// Create them with NewDrawOptions, which sets unit scale and opaque white.
+type DrawOptions struct {
+	OriginX, OriginY float32
+	ScaleX, ScaleY   float32
+	// Rotation and the skews are in degrees. Skewing shears the quad along
+	// each axis around its origin before it is rotated.
+	Rotation     float32
+	SkewX, SkewY float32
+	// Flipping mirrors the texture within the quad, leaving the origin where
+	// it is.
+	FlipX, FlipY bool
+	// Colors and Alphas tint the top left, top right, bottom right and
+	// bottom left corners, and are blended across the quad.
+	Colors [4]uint32
+	Alphas [4]float32
+	// UV, if set, is drawn instead of the drawable's View.
+	UV *[4]float32
+}
+
+func NewDrawOptions() *DrawOptions {
+	return &DrawOptions{
+		ScaleX: 1,
+		ScaleY: 1,
+		Colors: [4]uint32{0xffffff, 0xffffff, 0xffffff, 0xffffff},
+		Alphas: [4]float32{1, 1, 1, 1},
+	}
+}
+
+// SetColor tints every corner the same.
+func (o *DrawOptions) SetColor(color uint32, transparency float32) {
+	for i := range o.Colors {
+		o.Colors[i] = color
+		o.Alphas[i] = transparency
+	}
+}
+
+// SetTint tints every corner with c.
+func (o *DrawOptions) SetTint(c Color) {
+	o.SetColor(c.Hex(), c.A)
+}
+
+// SetCornerTints tints the top left, top right, bottom right and bottom left
+// corners.
+func (o *DrawOptions) SetCornerTints(tl, tr, br, bl Color) {
+	for i, c := range [4]Color{tl, tr, br, bl} {
+		o.Colors[i] = c.Hex()
+		o.Alphas[i] = c.A
+	}
+}
+
+// DrawWith draws r at x, y as described by o.
+func (b *Batch) DrawWith(r Drawable, x, y float32, o *DrawOptions) {
+	w, h := r.Width(), r.Height()
+	originX := o.OriginX * w
+	originY := o.OriginY * h
+
+	fx := -originX * o.ScaleX
+	fy := -originY * o.ScaleY
+	fx2 := (w - originX) * o.ScaleX
+	fy2 := (h - originY) * o.ScaleY
+
+	corners := [8]float32{fx, fy, fx2, fy, fx2, fy2, fx, fy2}
+
+	if o.SkewX != 0 || o.SkewY != 0 {
+		kx := float32(math.Tan(float64(o.SkewX * (math.Pi / 180.0))))
+		ky := float32(math.Tan(float64(o.SkewY * (math.Pi / 180.0))))
+		for i := 0; i < 8; i += 2 {
+			px, py := corners[i], corners[i+1]
+			corners[i] = px + kx*py
+			corners[i+1] = py + ky*px
+		}
+	}
+
+	if o.Rotation != 0 {
+		rot := float64(o.Rotation * (math.Pi / 180.0))
+		cos := float32(math.Cos(rot))
+		sin := float32(math.Sin(rot))
+		for i := 0; i < 8; i += 2 {
+			px, py := corners[i], corners[i+1]
+			corners[i] = cos*px - sin*py
+			corners[i+1] = sin*px + cos*py
+		}
+	}
+
+	var u, v, u2, v2 float32
+	if o.UV != nil {
+		u, v, u2, v2 = o.UV[0], o.UV[1], o.UV[2], o.UV[3]
+	} else {
+		u, v, u2, v2 = r.View()
+	}
+	if o.FlipX {
+		u, u2 = u2, u
+	}
+	if o.FlipY {
+		v, v2 = v2, v
+	}
+
+	b.quad(r.Texture(), &[20]float32{
+		x + corners[0], y + corners[1], u, v, packColor(o.Colors[0], o.Alphas[0]),
+		x + corners[2], y + corners[3], u2, v, packColor(o.Colors[1], o.Alphas[1]),
+		x + corners[4], y + corners[5], u2, v2, packColor(o.Colors[2], o.Alphas[2]),
+		x + corners[6], y + corners[7], u, v2, packColor(o.Colors[3], o.Alphas[3]),
+	})
+}
+
+// quadIndices splits a quad into two triangles.
+var quadIndices = []uint16{0, 1, 2, 0, 2, 3}
+
+// quad appends four vertices, each x, y, u, v and a packed tint, given
+// clockwise from the top left corner.
+func (b *Batch) quad(texture *webgl.Texture, q *[20]float32) {
+	b.mesh(texture, q[:], quadIndices)
+}
+
+// Vertex is a corner of a mesh. U and V span the drawable the mesh is drawn
+// with from 0 to 1, even if it is a region of a larger texture.
+type Vertex struct {
+	X, Y  float32
+	U, V  float32
+	Color uint32
+	Alpha float32
+}
+
+// SetTint sets the vertex's Color and Alpha from c.
+func (v *Vertex) SetTint(c Color) {
+	v.Color = c.Hex()
+	v.Alpha = c.A
+}
+
+// DrawMesh draws triangles textured with r, batched along with sprites.
+// Every three indices into vertices form a triangle, so there must be a
+// multiple of three and each must be less than len(vertices).
+func (b *Batch) DrawMesh(r Drawable, vertices []Vertex, indices []uint16) {
+	if len(vertices) > b.maxVertices || len(indices) > b.maxIndices {
+		log.Fatal("Batch.DrawMesh() mesh is larger than the batch")
+	}
+	if len(indices)%3 != 0 {
+		log.Fatalf("Batch.DrawMesh() %d indices is not a whole number of triangles", len(indices))
+	}
+	for i, index := range indices {
+		if int(index) >= len(vertices) {
+			log.Fatalf("Batch.DrawMesh() index %d is %d, but there are only %d vertices", i, index, len(vertices))
+		}
+	}
 
 	u, v, u2, v2 := r.View()
+	du, dv := u2-u, v2-v
+
+	data := make([]float32, len(vertices)*5)
+	for i, vert := range vertices {
+		data[i*5+0] = vert.X
+		data[i*5+1] = vert.Y
+		data[i*5+2] = u + vert.U*du
+		data[i*5+3] = v + vert.V*dv
+		data[i*5+4] = packColor(vert.Color, vert.Alpha)
+	}
 
-	b.vertices[idx+0] = x1
-	b.vertices[idx+1] = y1
-	b.vertices[idx+2] = u
-	b.vertices[idx+3] = v
-	b.vertices[idx+4] = tint
+	b.mesh(r.Texture(), data, indices)
+}
 
-	b.vertices[idx+5] = x4
-	b.vertices[idx+6] = y4
-	b.vertices[idx+7] = u2
-	b.vertices[idx+8] = v
-	b.vertices[idx+9] = tint
+// mesh appends vertices, five floats each, and indices relative to them. In
+// sorted mode they are held until End.
+func (b *Batch) mesh(texture *webgl.Texture, vertices []float32, indices []uint16) {
+	if !b.drawing {
+		log.Fatal("Batch.Begin() must be called first")
+	}
 
-	b.vertices[idx+10] = x3
-	b.vertices[idx+11] = y3
-	b.vertices[idx+12] = u2
-	b.vertices[idx+13] = v2
-	b.vertices[idx+14] = tint
+	if b.sorted {
+		b.deferred.add(texture, b.layer, b.depth, vertices, indices)
+		return
+	}
 
-	b.vertices[idx+15] = x2
-	b.vertices[idx+16] = y2
-	b.vertices[idx+17] = u
-	b.vertices[idx+18] = v2
-	b.vertices[idx+19] = tint
+	b.emit(texture, vertices, indices)
+}
 
-	b.index += 1
+// emit writes a mesh into the vertex and index buffers, tagging each vertex
+// with the texture unit its texture is bound to. The batch is only flushed
+// when every unit is taken by another texture, or the buffers are full.
+func (b *Batch) emit(texture *webgl.Texture, vertices []float32, indices []uint16) {
+	n := len(vertices) / 5
+	if b.vertexCount+n > b.maxVertices || b.indexCount+len(indices) > b.maxIndices {
+		b.flush(flushFull)
+	}
 
-	if b.index >= size {
-		b.flush()
+	unit := -1
+	for i, t := range b.textures {
+		if t == texture {
+			unit = i
+			break
+		}
+	}
+	if unit < 0 {
+		if len(b.textures) == textureUnits {
+			b.flush(flushTexture)
+		}
+		unit = len(b.textures)
+		b.textures = append(b.textures, texture)
+	}
+
+	slot := float32(unit)
+	idx := b.vertexCount * 6
+	for i := 0; i < n; i++ {
+		copy(b.vertices[idx+i*6:], vertices[i*5:i*5+5])
+		b.vertices[idx+i*6+5] = slot
+	}
+
+	base := uint16(b.vertexCount)
+	for i, index := range indices {
+		b.indices[b.indexCount+i] = base + index
 	}
+
+	b.vertexCount += n
+	b.indexCount += len(indices)
+	b.sprites += 1
+}
+
+// packColor packs an RGB color and transparency into the float32 bit
+// pattern read by the shader as four normalized bytes.
+func packColor(color uint32, transparency float32) float32 {
+	red := (color >> 16) & 0xFF
+	green := ((color >> 8) & 0xFF) << 8
+	blue := (color & 0xFF) << 16
+	alpha := uint32(transparency*255.0) << 24
+	return math.Float32frombits((alpha | blue | green | red) & 0xfeffffff)
 }
@@ -0,0 +1,158 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+// Unload frees the resource called name and forgets it, however many
+// references it has.
+func (l *Loader) Unload(name string) {
+	l.drop(name)
+	delete(l.refs, name)
+	delete(l.effects, name)
+}
+
+// Retain adds a reference to the resource called name, if it is loaded. Each
+// load of a resource also adds one.
+func (l *Loader) Retain(name string) {
+	if _, ok := l.assets[name]; ok {
+		l.refs[name]++
+	}
+}
+
+// Release removes a reference to the resource called name, unloading it
+// once none are left.
+func (l *Loader) Release(name string) {
+	if _, ok := l.refs[name]; !ok {
+		return
+	}
+	l.refs[name]--
+	if l.refs[name] <= 0 {
+		l.Unload(name)
+	}
+}
+
+// put stores asset as the resource called name, freeing any already there.
+// Loads skip names that are already loaded, so this only happens when a
+// resource is replaced by one of another type.
+func (l *Loader) put(name, kind string, asset interface{}) {
+	source, watched := l.sources[name]
+	l.drop(name)
+	l.assets[name] = asset
+	if watched {
+		l.sources[name] = source
+	}
+
+	if _, ok := loaders[kind]; ok {
+		return
+	}
+	switch kind {
+	case "image":
+		l.images[name] = asset.(*Texture)
+	case "ninepatch":
+		patch := asset.(*NinePatch)
+		l.patches[name] = patch
+		l.images[name] = patch.Region.texture
+	case "json":
+		l.jsons[name] = asset.(string)
+	case "text", "xml", "shader":
+		l.texts[name] = asset.(string)
+	case "binary":
+		l.blobs[name] = asset.([]byte)
+	case "sound":
+		l.sounds[name] = asset.(*Sound)
+	}
+}
+
+// drop frees the resource called name and removes it from every map except
+// its reference count.
+func (l *Loader) drop(name string) {
+	asset, ok := l.assets[name]
+	if !ok {
+		return
+	}
+
+	switch a := asset.(type) {
+	case *Texture:
+		a.Delete()
+	case *NinePatch:
+		a.Region.texture.Delete()
+	case *Sound:
+		if a.Player != nil {
+			a.Close()
+		}
+	case interface {
+		Delete()
+	}:
+		a.Delete()
+	}
+
+	delete(l.assets, name)
+	delete(l.sources, name)
+	delete(l.images, name)
+	delete(l.patches, name)
+	delete(l.jsons, name)
+	delete(l.sounds, name)
+	delete(l.texts, name)
+	delete(l.blobs, name)
+	for key := range l.decoded {
+		if key.name == name {
+			delete(l.decoded, key)
+		}
+	}
+}
+
+// Bundle is a group of resources loaded and unloaded together, such as those
+// used by one level. Resources shared between bundles stay loaded until
+// every bundle holding them is unloaded.
+type Bundle struct {
+	loader    *Loader
+	resources []Resource
+	held      []string
+}
+
+// Bundle returns the bundle called name, creating it if needed.
+func (l *Loader) Bundle(name string) *Bundle {
+	b, ok := l.bundles[name]
+	if !ok {
+		b = &Bundle{loader: l}
+		l.bundles[name] = b
+	}
+	return b
+}
+
+// Add adds the file at url to the bundle under name, as Loader.Add does.
+func (b *Bundle) Add(name, url string, opts ...TextureOptions) {
+	b.AddAs(extensions[extension(url)], name, url, opts...)
+}
+
+// AddAs adds the file at url to the bundle under name as kind, as
+// Loader.AddAs does.
+func (b *Bundle) AddAs(kind, name, url string, opts ...TextureOptions) {
+	b.resources = append(b.resources, newResource(kind, name, url, opts))
+}
+
+// Load loads the bundle's resources in the background, as Loader.Load does,
+// taking a reference to each. Resources that are already loaded, or being
+// loaded, are shared rather than loaded again.
+func (b *Bundle) Load(onFinish func()) {
+	l := b.loader
+	resources := b.resources
+	l.start(resources, false, func() {
+		for _, r := range resources {
+			if _, ok := l.assets[r.name]; ok {
+				b.held = append(b.held, r.name)
+			}
+		}
+		onFinish()
+	})
+}
+
+// Unload releases the bundle's references, unloading the resources no other
+// bundle or caller of Retain still holds.
+func (b *Bundle) Unload() {
+	for _, name := range b.held {
+		b.loader.Release(name)
+	}
+	b.held = nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"testing"
+	"time"
+)
+
+// finishLoads runs the loader's updates until every job has finished.
+func finishLoads(t *testing.T, l *Loader) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(l.jobs) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("loads did not finish")
+		}
+		l.update()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoaderSharesResources(t *testing.T) {
+	l := NewLoader()
+	l.FS = NewFS()
+	l.FS.Mount("", MapSource{
+		"a.txt": []byte("a"),
+		"b.txt": []byte("b"),
+		"c.txt": []byte("c"),
+	})
+
+	level1 := l.Bundle("level1")
+	level1.Add("a", "a.txt")
+	level1.Add("b", "b.txt")
+	level2 := l.Bundle("level2")
+	level2.Add("b", "b.txt")
+	level2.Add("c", "c.txt")
+	l.Add("a", "a.txt")
+	l.Add("a", "a.txt")
+
+	var finished []string
+	l.Load(func() { finished = append(finished, "loader") })
+	level1.Load(func() { finished = append(finished, "level1") })
+	level2.Load(func() { finished = append(finished, "level2") })
+
+	if reads := len(l.jobs[0].queued) + len(l.jobs[1].queued) + len(l.jobs[2].queued); reads != 3 {
+		t.Errorf("%d files are being read, want 3", reads)
+	}
+
+	finishLoads(t, l)
+	if len(finished) != 3 {
+		t.Errorf("finished %v, want all three loads", finished)
+	}
+
+	refs := map[string]int{"a": 3, "b": 2, "c": 1}
+	for name, want := range refs {
+		if l.refs[name] != want {
+			t.Errorf("%s has %d references, want %d", name, l.refs[name], want)
+		}
+	}
+
+	level1.Unload()
+	if l.Text("b") != "b" || l.Text("a") != "a" {
+		t.Error("unloading level1 freed resources still in use")
+	}
+	level2.Unload()
+	if _, ok := l.assets["b"]; ok {
+		t.Error("b is still loaded after every bundle holding it unloaded")
+	}
+	if _, ok := l.assets["c"]; ok {
+		t.Error("c is still loaded after its bundle unloaded")
+	}
+	if l.refs["a"] != 2 {
+		t.Errorf("a has %d references, want 2", l.refs["a"])
+	}
+
+	// Loading a loaded resource again only adds a reference.
+	l.Add("a", "a.txt")
+	l.Load(func() {})
+	if len(l.jobs[0].queued) != 0 {
+		t.Error("a loaded resource was read again")
+	}
+	finishLoads(t, l)
+	if l.refs["a"] != 3 {
+		t.Errorf("a has %d references, want 3", l.refs["a"])
+	}
+}
+
+func TestLoaderWaitsForFailedResources(t *testing.T) {
+	l := NewLoader()
+	l.FS = NewFS()
+	l.FS.Mount("", MapSource{})
+
+	l.Add("missing", "missing.txt")
+	level := l.Bundle("level")
+	level.Add("missing", "missing.txt")
+
+	l.Load(func() {})
+	level.Load(func() {})
+	finishLoads(t, l)
+
+	if len(level.held) != 0 || len(l.pending) != 0 || l.refs["missing"] != 0 {
+		t.Errorf("failed resource left held %v, pending %v and refs %v", level.held, l.pending, l.refs)
+	}
+}
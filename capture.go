@@ -0,0 +1,183 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"path/filepath"
+	"sync"
+)
+
+// Screenshot reads back the framebuffer currently being drawn to, which is
+// the screen unless a render target is bound. Call it from Render after
+// drawing; the web backend discards the canvas once a frame is presented.
+func Screenshot() (*image.NRGBA, error) {
+	w, h := viewport()
+	img, err := readPixels(w, h)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xFF
+		}
+	}
+	return img, nil
+}
+
+// Image reads back the contents of the render target.
+func (r *RenderTarget) Image() (*image.NRGBA, error) {
+	r.Begin()
+	defer r.End()
+	return readPixels(r.texture.width, r.texture.height)
+}
+
+// readPixels copies the bound framebuffer into an image, flipping it so the
+// first row is the top of the frame.
+func readPixels(width, height int) (*image.NRGBA, error) {
+	if gl == nil {
+		return nil, errors.New("engi: no graphics context")
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("engi: cannot read a %dx%d framebuffer", width, height)
+	}
+
+	pixels := make([]uint8, width*height*4)
+	gl.ReadPixels(0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE, pixels)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	stride := width * 4
+	for y := 0; y < height; y++ {
+		src := pixels[(height-1-y)*stride : (height-y)*stride]
+		copy(img.Pix[y*img.Stride:], src)
+	}
+
+	return img, nil
+}
+
+// SavePNG writes img to path as a PNG. On the web there is no file system,
+// so the browser downloads it instead, named after the last element of path.
+func SavePNG(path string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+type frameCapture struct {
+	dir       string
+	remaining int
+	captured  int
+	index     int
+	frames    chan *image.NRGBA
+	done      sync.WaitGroup
+	mu        sync.Mutex
+	err       error
+}
+
+var capturing, lastCapture *frameCapture
+
+// CaptureFrames saves the next count frames to dir as frame00000.png,
+// frame00001.png and so on. Frames are read back on the render thread and
+// encoded in the background; frames that arrive while the encoder is too
+// far behind are dropped, which WaitCapture reports. On the web each frame
+// is downloaded, as SavePNG does, and dir is ignored.
+func CaptureFrames(dir string, count int) error {
+	if capturing != nil {
+		return errors.New("engi: frame capture already in progress")
+	}
+	if count <= 0 {
+		return fmt.Errorf("engi: cannot capture %d frames", count)
+	}
+	if err := makeDir(dir); err != nil {
+		return err
+	}
+
+	c := &frameCapture{
+		dir:       dir,
+		remaining: count,
+		frames:    make(chan *image.NRGBA, 8),
+	}
+	c.done.Add(1)
+	go c.write()
+	capturing = c
+	lastCapture = c
+
+	return nil
+}
+
+// Capturing reports whether a frame capture is in progress.
+func Capturing() bool {
+	return capturing != nil
+}
+
+// captureFrame is called by the backends once a frame has been rendered.
+func captureFrame() {
+	c := capturing
+	if c == nil {
+		return
+	}
+
+	// Waiting for the encoder would stall the game loop, and on the web
+	// block the animation frame callback, so a frame it has fallen too far
+	// behind to take is dropped and reported instead.
+	img, err := Screenshot()
+	if err != nil {
+		c.fail(err)
+	} else {
+		select {
+		case c.frames <- img:
+		default:
+			c.fail(fmt.Errorf("engi: dropped frame %d, encoding fell behind", c.captured))
+		}
+	}
+	c.captured++
+
+	c.remaining--
+	if c.remaining <= 0 {
+		close(c.frames)
+		capturing = nil
+	}
+}
+
+// WaitCapture waits for the frames of the last finished capture to be
+// written and returns the first error encountered.
+func WaitCapture() error {
+	if capturing != nil {
+		return errors.New("engi: frame capture still in progress")
+	}
+	c := lastCapture
+	if c == nil {
+		return nil
+	}
+	c.done.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *frameCapture) write() {
+	defer c.done.Done()
+	for img := range c.frames {
+		name := filepath.Join(c.dir, fmt.Sprintf("frame%05d.png", c.index))
+		c.index++
+		if err := SavePNG(name, img); err != nil {
+			c.fail(err)
+		}
+	}
+}
+
+func (c *frameCapture) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+}
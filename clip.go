@@ -0,0 +1,148 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"log"
+	"math"
+)
+
+// clipRect is a scissor rectangle in framebuffer pixels, with the origin in
+// the bottom left corner.
+type clipRect struct {
+	x, y, w, h int
+}
+
+func (a clipRect) intersect(b clipRect) clipRect {
+	x := maxInt(a.x, b.x)
+	y := maxInt(a.y, b.y)
+	x2 := minInt(a.x+a.w, b.x+b.w)
+	y2 := minInt(a.y+a.h, b.y+b.h)
+	return clipRect{x, y, maxInt(x2-x, 0), maxInt(y2-y, 0)}
+}
+
+// PushClip restricts drawing to the rectangle x, y, w, h, given in the
+// batch's projected coordinates. Clips nest, each limited to the one before,
+// and every one must be popped before End.
+// In sorted mode, draws made before the clip changes are sorted and drawn
+// first.
+func (b *Batch) PushClip(x, y, w, h float32) {
+	b.barrier()
+
+	vw, vh := viewport()
+	sx := float32(vw) / (b.projX * 2)
+	sy := float32(vh) / (b.projY * 2)
+
+	// Every edge rounds to the nearest pixel, so clips that share an edge
+	// meet exactly.
+	left := roundInt(x * sx)
+	right := roundInt((x + w) * sx)
+	bottom := vh - roundInt((y+h)*sy)
+	top := vh - roundInt(y*sy)
+	rect := clipRect{left, bottom, maxInt(right-left, 0), maxInt(top-bottom, 0)}
+
+	if n := len(b.clips); n > 0 {
+		rect = b.clips[n-1].intersect(rect)
+	}
+	b.clips = append(b.clips, rect)
+	b.applyClip()
+}
+
+// PopClip restores the clip that was active before the last PushClip.
+func (b *Batch) PopClip() {
+	if len(b.clips) == 0 {
+		log.Fatal("Batch.PopClip() called without a matching PushClip()")
+	}
+	b.barrier()
+	b.clips = b.clips[:len(b.clips)-1]
+	b.applyClip()
+}
+
+func (b *Batch) applyClip() {
+	n := len(b.clips)
+	if n == 0 {
+		gl.Disable(gl.SCISSOR_TEST)
+		return
+	}
+	c := b.clips[n-1]
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(c.x, c.y, c.w, c.h)
+}
+
+// BeginMask starts drawing a stencil mask. Until EndMask, draws mark where
+// later draws may appear instead of drawing color. Fully transparent pixels
+// of sprites are left out of the mask, so any drawable can shape it.
+func (b *Batch) BeginMask() {
+	b.barrier()
+
+	gl.Enable(gl.STENCIL_TEST)
+	gl.ClearStencil(0)
+	gl.Clear(gl.STENCIL_BUFFER_BIT)
+	gl.ColorMask(false, false, false, false)
+	gl.StencilFunc(gl.ALWAYS, 1, 0xFF)
+	gl.StencilOp(gl.KEEP, gl.KEEP, gl.REPLACE)
+	gl.Uniform1f(b.ufAlphaTest, 0.01)
+	b.masking = true
+}
+
+// EndMask finishes the mask. Subsequent draws only appear inside it, or
+// outside of it if inverted, until ClearMask or End.
+func (b *Batch) EndMask(inverted bool) {
+	b.barrier()
+
+	gl.ColorMask(true, true, true, true)
+	if inverted {
+		gl.StencilFunc(gl.NOTEQUAL, 1, 0xFF)
+	} else {
+		gl.StencilFunc(gl.EQUAL, 1, 0xFF)
+	}
+	gl.StencilOp(gl.KEEP, gl.KEEP, gl.KEEP)
+	gl.Uniform1f(b.ufAlphaTest, 0)
+}
+
+// ClearMask stops masking draws.
+func (b *Batch) ClearMask() {
+	b.barrier()
+	b.disableMask()
+}
+
+func (b *Batch) disableMask() {
+	if !b.masking {
+		return
+	}
+	gl.ColorMask(true, true, true, true)
+	gl.Disable(gl.STENCIL_TEST)
+	gl.Uniform1f(b.ufAlphaTest, 0)
+	b.masking = false
+}
+
+// barrier draws everything queued so far before a change of state.
+func (b *Batch) barrier() {
+	if !b.drawing {
+		log.Fatal("Batch.Begin() must be called first")
+	}
+	if len(b.deferred.draws) > 0 {
+		b.drawDeferred()
+	}
+	b.flush(flushState)
+}
+
+func roundInt(f float32) int {
+	return int(math.Floor(float64(f) + 0.5))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
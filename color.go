@@ -0,0 +1,256 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Color is a color with straight alpha, each channel from 0 to 1.
+type Color struct {
+	R, G, B, A float32
+}
+
+// Hex returns the opaque color 0xRRGGBB, the form taken by SetBg and Draw.
+func Hex(rgb uint32) Color {
+	return Color{
+		float32((rgb>>16)&0xFF) / 255,
+		float32((rgb>>8)&0xFF) / 255,
+		float32(rgb&0xFF) / 255,
+		1,
+	}
+}
+
+// HexA returns the color 0xRRGGBBAA.
+func HexA(rgba uint32) Color {
+	c := Hex(rgba >> 8)
+	c.A = float32(rgba&0xFF) / 255
+	return c
+}
+
+// ParseColor reads a color written as #rgb, #rgba, #rrggbb or #rrggbbaa, with
+// or without the #, or as the name of a color in Palette.
+func ParseColor(s string) (Color, error) {
+	if c, ok := Palette[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long []byte
+		for i := 0; i < len(hex); i++ {
+			long = append(long, hex[i], hex[i])
+		}
+		hex = string(long)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || (len(hex) != 6 && len(hex) != 8) {
+		return Color{}, fmt.Errorf("engi: invalid color %q", s)
+	}
+	if len(hex) == 6 {
+		return Hex(uint32(v)), nil
+	}
+	return HexA(uint32(v)), nil
+}
+
+// HSV returns the opaque color with hue h in degrees, and saturation s and
+// value v from 0 to 1.
+func HSV(h, s, v float32) Color {
+	c := v * s
+	return hueColor(h, c, v-c)
+}
+
+// HSL returns the opaque color with hue h in degrees, and saturation s and
+// lightness l from 0 to 1.
+func HSL(h, s, l float32) Color {
+	c := (1 - float32(math.Abs(float64(2*l-1)))) * s
+	return hueColor(h, c, l-c/2)
+}
+
+// hueColor builds a color from a hue, its chroma c and the amount m added to
+// every channel.
+func hueColor(h, c, m float32) Color {
+	h = mod(h, 360) / 60
+	x := c * (1 - float32(math.Abs(math.Mod(float64(h), 2)-1)))
+
+	var r, g, b float32
+	switch int(h) {
+	case 0:
+		r, g, b = c, x, 0
+	case 1:
+		r, g, b = x, c, 0
+	case 2:
+		r, g, b = 0, c, x
+	case 3:
+		r, g, b = 0, x, c
+	case 4:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return Color{r + m, g + m, b + m, 1}
+}
+
+// HSV returns the hue of the color in degrees, and its saturation and value.
+func (c Color) HSV() (float32, float32, float32) {
+	max, min, h := c.hue()
+	if max == 0 {
+		return h, 0, 0
+	}
+	return h, (max - min) / max, max
+}
+
+// HSL returns the hue of the color in degrees, and its saturation and
+// lightness.
+func (c Color) HSL() (float32, float32, float32) {
+	max, min, h := c.hue()
+	l := (max + min) / 2
+	if max == min {
+		return h, 0, l
+	}
+	return h, (max - min) / (1 - float32(math.Abs(float64(2*l-1)))), l
+}
+
+func (c Color) hue() (float32, float32, float32) {
+	max := float32(math.Max(float64(c.R), math.Max(float64(c.G), float64(c.B))))
+	min := float32(math.Min(float64(c.R), math.Min(float64(c.G), float64(c.B))))
+	d := max - min
+
+	var h float32
+	switch {
+	case d == 0:
+		h = 0
+	case max == c.R:
+		h = mod((c.G-c.B)/d, 6)
+	case max == c.G:
+		h = (c.B-c.R)/d + 2
+	default:
+		h = (c.R-c.G)/d + 4
+	}
+
+	return max, min, h * 60
+}
+
+// Hex returns the color as 0xRRGGBB, dropping alpha.
+func (c Color) Hex() uint32 {
+	return uint32(clamp01(c.R)*255+0.5)<<16 |
+		uint32(clamp01(c.G)*255+0.5)<<8 |
+		uint32(clamp01(c.B)*255+0.5)
+}
+
+// HexA returns the color as 0xRRGGBBAA.
+func (c Color) HexA() uint32 {
+	return c.Hex()<<8 | uint32(clamp01(c.A)*255+0.5)
+}
+
+func (c Color) String() string {
+	return fmt.Sprintf("#%08x", c.HexA())
+}
+
+// WithAlpha returns the color with its alpha replaced.
+func (c Color) WithAlpha(a float32) Color {
+	c.A = a
+	return c
+}
+
+// Lerp blends linearly from c to o as t goes from 0 to 1.
+func (c Color) Lerp(o Color, t float32) Color {
+	return Color{
+		c.R + (o.R-c.R)*t,
+		c.G + (o.G-c.G)*t,
+		c.B + (o.B-c.B)*t,
+		c.A + (o.A-c.A)*t,
+	}
+}
+
+// Blend composites o over c, as alpha blending does when drawing.
+func (c Color) Blend(o Color) Color {
+	a := o.A + c.A*(1-o.A)
+	if a == 0 {
+		return Color{}
+	}
+	return Color{
+		(o.R*o.A + c.R*c.A*(1-o.A)) / a,
+		(o.G*o.A + c.G*c.A*(1-o.A)) / a,
+		(o.B*o.A + c.B*c.A*(1-o.A)) / a,
+		a,
+	}
+}
+
+// Multiply returns the product of each channel, which is how a tint affects
+// a texture.
+func (c Color) Multiply(o Color) Color {
+	return Color{c.R * o.R, c.G * o.G, c.B * o.B, c.A * o.A}
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+var (
+	Transparent = Color{0, 0, 0, 0}
+	Black       = Hex(0x000000)
+	White       = Hex(0xffffff)
+	Gray        = Hex(0x808080)
+	LightGray   = Hex(0xc0c0c0)
+	DarkGray    = Hex(0x404040)
+	Red         = Hex(0xff0000)
+	Green       = Hex(0x008000)
+	Blue        = Hex(0x0000ff)
+	Yellow      = Hex(0xffff00)
+	Cyan        = Hex(0x00ffff)
+	Magenta     = Hex(0xff00ff)
+	Orange      = Hex(0xffa500)
+	Purple      = Hex(0x800080)
+	Pink        = Hex(0xffc0cb)
+	Brown       = Hex(0xa52a2a)
+	Maroon      = Hex(0x800000)
+	Olive       = Hex(0x808000)
+	Navy        = Hex(0x000080)
+	Teal        = Hex(0x008080)
+	Lime        = Hex(0x00ff00)
+	LimeGreen   = Hex(0x32cd32)
+	Gold        = Hex(0xffd700)
+	SkyBlue     = Hex(0x87ceeb)
+)
+
+// Palette maps lower case names to the built-in colors.
+var Palette = map[string]Color{
+	"transparent": Transparent,
+	"black":       Black,
+	"white":       White,
+	"gray":        Gray,
+	"lightgray":   LightGray,
+	"darkgray":    DarkGray,
+	"red":         Red,
+	"green":       Green,
+	"blue":        Blue,
+	"yellow":      Yellow,
+	"cyan":        Cyan,
+	"magenta":     Magenta,
+	"orange":      Orange,
+	"purple":      Purple,
+	"pink":        Pink,
+	"brown":       Brown,
+	"maroon":      Maroon,
+	"olive":       Olive,
+	"navy":        Navy,
+	"teal":        Teal,
+	"lime":        Lime,
+	"limegreen":   LimeGreen,
+	"gold":        Gold,
+	"skyblue":     SkyBlue,
+}
@@ -0,0 +1,111 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint32 // 0xRRGGBBAA
+		err  bool
+	}{
+		{"#ff8000", 0xff8000ff, false},
+		{"ff8000", 0xff8000ff, false},
+		{"#FF8000", 0xff8000ff, false},
+		{"#f80", 0xff8800ff, false},
+		{"#f808", 0xff880088, false},
+		{"#ff800080", 0xff800080, false},
+		{"green", 0x008000ff, false},
+		{"Lime", 0x00ff00ff, false},
+		{"limegreen", 0x32cd32ff, false},
+		{"transparent", 0x00000000, false},
+		{"", 0, true},
+		{"#", 0, true},
+		{"#12345", 0, true},
+		{"#ggg", 0, true},
+		{"#ff80001", 0, true},
+		{"chartreuse", 0, true},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseColor(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseColor(%q) = %v, want an error", tt.in, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseColor(%q): %v", tt.in, err)
+			continue
+		}
+		if got := c.HexA(); got != tt.want {
+			t.Errorf("ParseColor(%q) = %08x, want %08x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHSV(t *testing.T) {
+	tests := []struct {
+		h, s, v float32
+		want    uint32
+	}{
+		{0, 1, 1, 0xff0000},
+		{60, 1, 1, 0xffff00},
+		{120, 1, 1, 0x00ff00},
+		{180, 1, 1, 0x00ffff},
+		{240, 1, 1, 0x0000ff},
+		{300, 1, 1, 0xff00ff},
+		{360, 1, 1, 0xff0000},
+		{-120, 1, 1, 0x0000ff},
+		{0, 0, 0.5, 0x808080},
+		{30, 1, 0.5, 0x804000},
+	}
+
+	for _, tt := range tests {
+		c := HSV(tt.h, tt.s, tt.v)
+		if got := c.Hex(); got != tt.want {
+			t.Errorf("HSV(%v, %v, %v) = %06x, want %06x", tt.h, tt.s, tt.v, got, tt.want)
+		}
+
+		// Converting back must give the same color.
+		h, s, v := c.HSV()
+		back := HSV(h, s, v)
+		if back.Hex() != c.Hex() {
+			t.Errorf("HSV of %06x = %v, %v, %v, which is %06x", c.Hex(), h, s, v, back.Hex())
+		}
+		if tt.s > 0 && math.Abs(float64(s-tt.s)) > 1e-3 {
+			t.Errorf("saturation of %06x = %v, want %v", c.Hex(), s, tt.s)
+		}
+	}
+}
+
+func TestHSL(t *testing.T) {
+	tests := []struct {
+		h, s, l float32
+		want    uint32
+	}{
+		{0, 1, 0.5, 0xff0000},
+		{120, 1, 0.25, 0x008000},
+		{240, 1, 0.75, 0x8080ff},
+		{0, 0, 1, 0xffffff},
+		{0, 0, 0, 0x000000},
+	}
+
+	for _, tt := range tests {
+		c := HSL(tt.h, tt.s, tt.l)
+		if got := c.Hex(); got != tt.want {
+			t.Errorf("HSL(%v, %v, %v) = %06x, want %06x", tt.h, tt.s, tt.l, got, tt.want)
+		}
+		h, s, l := c.HSL()
+		if back := HSL(h, s, l); back.Hex() != c.Hex() {
+			t.Errorf("HSL of %06x = %v, %v, %v, which is %06x", c.Hex(), h, s, l, back.Hex())
+		}
+	}
+}
@@ -11,6 +11,8 @@ var (
 	Time      *Clock
 	Files     *Loader
 	gl        *webgl.Context
+
+	bgRed, bgGreen, bgBlue float32
 )
 
 func Open(title string, width, height int, fullscreen bool, r Responder) {
@@ -21,10 +23,15 @@ func Open(title string, width, height int, fullscreen bool, r Responder) {
 }
 
 func SetBg(color uint32) {
-	r := float32((color>>16)&0xFF) / 255.0
-	g := float32((color>>8)&0xFF) / 255.0
-	b := float32(color&0xFF) / 255.0
-	gl.ClearColor(r, g, b, 1.0)
+	bgRed = float32((color>>16)&0xFF) / 255.0
+	bgGreen = float32((color>>8)&0xFF) / 255.0
+	bgBlue = float32(color&0xFF) / 255.0
+	gl.ClearColor(bgRed, bgGreen, bgBlue, 1.0)
+}
+
+// SetBgColor is SetBg for a Color. The background is always opaque.
+func SetBgColor(c Color) {
+	SetBg(c.Hex())
 }
 
 func Width() float32 {
@@ -7,14 +7,14 @@
 package engi
 
 import (
+	"bytes"
+	"errors"
 	"image"
-	"image/draw"
-	_ "image/png"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/ajhager/webgl"
 	"github.com/go-gl/glfw/v3.1/glfw"
@@ -22,6 +22,13 @@ import (
 
 var window *glfw.Window
 
+// npotLimited reports whether textures with sides that are not powers of two
+// are restricted to clamped wrapping and no mipmaps.
+const npotLimited = false
+
+// canWatch reports whether files can be watched for hot reloading.
+const canWatch = true
+
 // fatalErr calls log.Fatal with the given error if it is non-nil.
 func fatalErr(err error) {
 	if err != nil {
@@ -54,6 +61,7 @@ func run(title string, width, height int, fullscreen bool) {
 
 	glfw.WindowHint(glfw.ContextVersionMajor, 2)
 	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.StencilBits, 8)
 
 	window, err := glfw.CreateWindow(width, height, title, monitor, nil)
 	fatalErr(err)
@@ -70,10 +78,10 @@ func run(title string, width, height int, fullscreen bool) {
 
 	gl = webgl.NewContext()
 
-	gl.Viewport(0, 0, width, height)
+	setViewport(width, height)
 	window.SetFramebufferSizeCallback(func(window *glfw.Window, w, h int) {
 		windowWidth, windowHeight = window.GetSize()
-		gl.Viewport(0, 0, w, h)
+		setViewport(w, h)
 		responder.Resize(float32(windowWidth), float32(windowHeight))
 	})
 
@@ -110,14 +118,27 @@ func run(title string, width, height int, fullscreen bool) {
 	})
 
 	responder.Preload()
-	Files.Load(func() {})
-	responder.Setup()
+	preloading := true
+	Files.Load(func() {
+		preloading = false
+		responder.Setup()
+	})
 
 	shouldClose := window.ShouldClose()
 	for !shouldClose {
-		responder.Update(Time.Delta())
-		gl.Clear(gl.COLOR_BUFFER_BIT)
-		responder.Render()
+		Files.update()
+		if preloading {
+			gl.Clear(gl.COLOR_BUFFER_BIT)
+			if r, ok := responder.(LoadingResponder); ok {
+				r.Loading(Files.Progress())
+			}
+		} else {
+			responder.Update(Time.Delta())
+			gl.Clear(gl.COLOR_BUFFER_BIT)
+			responder.Render()
+		}
+		captureFrame()
+		endFrame()
 		window.SwapBuffers()
 		glfw.PollEvents()
 		Time.Tick()
@@ -266,92 +287,66 @@ func (i *ImageObject) Height() int {
 	return i.data.Rect.Max.Y
 }
 
-func loadImage(r Resource) (Image, error) {
-	file, err := os.Open(r.url)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, err
-	}
-
-	b := img.Bounds()
-	newm := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
-	draw.Draw(newm, newm.Bounds(), img, b.Min, draw.Src)
+func newImage(pixels *image.NRGBA) Image {
+	return &ImageObject{pixels}
+}
 
-	return &ImageObject{newm}, nil
+func newBlankImage(width, height int) Image {
+	return &ImageObject{image.NewNRGBA(image.Rect(0, 0, width, height))}
 }
 
-func loadJson(r Resource) (string, error) {
-	file, err := ioutil.ReadFile(r.url)
-	if err != nil {
-		return "", err
+func newWhiteImage(width, height int) Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
 	}
-	return string(file), nil
+	return &ImageObject{img}
 }
 
-type Assets struct {
-	queue  []string
-	cache  map[string]Image
-	loads  int
-	errors int
+func imagePixels(img Image) (*image.NRGBA, error) {
+	if m, ok := img.Data().(*image.NRGBA); ok {
+		return m, nil
+	}
+	return nil, errors.New("engi: image has no pixel data")
 }
 
-func NewAssets() *Assets {
-	return &Assets{make([]string, 0), make(map[string]Image), 0, 0}
-}
+func loadImage(r Resource) (Image, int, error) {
+	data, err := ioutil.ReadFile(r.url)
+	if err != nil {
+		return nil, 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, len(data), err
+	}
 
-func (a *Assets) Image(path string) {
-	a.queue = append(a.queue, path)
+	return &ImageObject{nrgba(img)}, len(data), nil
 }
 
-func (a *Assets) Get(path string) Image {
-	return a.cache[path]
+// defaultFS mounts the working directory, where paths have always been
+// relative to on desktop.
+func defaultFS() *FS {
+	fs := NewFS()
+	fs.Mount("", DirSource("."))
+	return fs
 }
 
-func (a *Assets) Load(onFinish func()) {
-	if len(a.queue) == 0 {
-		onFinish()
-	} else {
-		for _, path := range a.queue {
-			img := LoadImage(path)
-			a.cache[path] = img
-		}
-	}
+func loadBytes(r Resource) ([]byte, error) {
+	return ioutil.ReadFile(r.url)
 }
 
-func LoadImage(data interface{}) Image {
-	var m image.Image
-
-	switch data := data.(type) {
-	default:
-		log.Fatal("NewTexture needs a string or io.Reader")
-	case string:
-		file, err := os.Open(data)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer file.Close()
-		img, _, err := image.Decode(file)
-		if err != nil {
-			log.Fatal(err)
-		}
-		m = img
-	case io.Reader:
-		img, _, err := image.Decode(data)
-		if err != nil {
-			log.Fatal(err)
-		}
-		m = img
-	case image.Image:
-		m = data
+func modTime(url string) (time.Time, error) {
+	info, err := os.Stat(url)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return info.ModTime(), nil
+}
 
-	b := m.Bounds()
-	newm := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
-	draw.Draw(newm, newm.Bounds(), m, b.Min, draw.Src)
+func writeFile(name string, data []byte) error {
+	return ioutil.WriteFile(name, data, 0644)
+}
 
-	return &ImageObject{newm}
+func makeDir(dir string) error {
+	return os.MkdirAll(dir, 0755)
 }
@@ -7,9 +7,12 @@
 package engi
 
 import (
+	"errors"
+	"image"
 	"log"
 	"math"
 	"math/rand"
+	"path"
 	"strconv"
 	"time"
 
@@ -25,6 +28,16 @@ func init() {
 var canvas *js.Object
 var keysDown map[int]bool
 
+// preloading is set until the resources queued by Preload have loaded.
+var preloading bool
+
+// npotLimited reports whether textures with sides that are not powers of two
+// are restricted to clamped wrapping and no mipmaps.
+const npotLimited = true
+
+// canWatch reports whether files can be watched for hot reloading.
+const canWatch = false
+
 func run(title string, width, height int, fullscreen bool) {
 	document := js.Global.Get("document")
 	canvas = document.Call("createElement", "canvas")
@@ -38,6 +51,7 @@ func run(title string, width, height int, fullscreen bool) {
 	attrs := webgl.DefaultAttributes()
 	attrs.Alpha = false
 	attrs.Depth = false
+	attrs.Stencil = true
 	attrs.PremultipliedAlpha = false
 	attrs.PreserveDrawingBuffer = false
 	attrs.Antialias = false
@@ -148,13 +162,15 @@ func run(title string, width, height int, fullscreen bool) {
 		responder.Key(Key(key), 0, RELEASE)
 	}, false)
 
-	gl.Viewport(0, 0, width, height)
+	setViewport(canvas.Get("width").Int(), canvas.Get("height").Int())
 
 	responder.Preload()
+	preloading = true
 	Files.Load(func() {
+		preloading = false
 		responder.Setup()
-		RequestAnimationFrame(animate)
 	})
+	RequestAnimationFrame(animate)
 }
 
 func width() float32 {
@@ -167,9 +183,19 @@ func height() float32 {
 
 func animate(dt float32) {
 	RequestAnimationFrame(animate)
-	responder.Update(Time.Delta())
-	gl.Clear(gl.COLOR_BUFFER_BIT)
-	responder.Render()
+	Files.update()
+	if preloading {
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+		if r, ok := responder.(LoadingResponder); ok {
+			r.Loading(Files.Progress())
+		}
+	} else {
+		responder.Update(Time.Delta())
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+		responder.Render()
+	}
+	captureFrame()
+	endFrame()
 	Time.Tick()
 }
 
@@ -221,7 +247,44 @@ func CancelAnimationFrame(id int) {
 	js.Global.Call("cancelAnimationFrame")
 }
 
-func loadImage(r Resource) (Image, error) {
+func newBlankImage(width, height int) Image {
+	img := js.Global.Get("document").Call("createElement", "canvas")
+	img.Set("width", width)
+	img.Set("height", height)
+	return &ImageObject{img}
+}
+
+func newWhiteImage(width, height int) Image {
+	img := newBlankImage(width, height).(*ImageObject)
+	ctx := img.data.Call("getContext", "2d")
+	ctx.Set("fillStyle", "#ffffff")
+	ctx.Call("fillRect", 0, 0, width, height)
+	return img
+}
+
+func imagePixels(img Image) (*image.NRGBA, error) {
+	source, ok := img.Data().(*js.Object)
+	if !ok {
+		return nil, errors.New("engi: image has no pixel data")
+	}
+
+	w, h := img.Width(), img.Height()
+	canvas := newBlankImage(w, h).(*ImageObject).data
+	ctx := canvas.Call("getContext", "2d")
+	ctx.Call("drawImage", source, 0, 0)
+	data := ctx.Call("getImageData", 0, 0, w, h).Get("data")
+
+	pixels := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range pixels.Pix {
+		pixels.Pix[i] = uint8(data.Index(i).Int())
+	}
+
+	return pixels, nil
+}
+
+// loadImage cannot see the size of the file behind an image element, so it
+// reports no bytes read.
+func loadImage(r Resource) (Image, int, error) {
 	ch := make(chan error, 1)
 
 	img := js.Global.Get("Image").New()
@@ -235,19 +298,27 @@ func loadImage(r Resource) (Image, error) {
 
 	err := <-ch
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return &ImageObject{img}, nil
+	return &ImageObject{img}, 0, nil
 }
 
-func loadJson(r Resource) (string, error) {
+// request fetches url, with the response decoded as responseType.
+func request(url, responseType string) (*js.Object, error) {
 	ch := make(chan error, 1)
 
 	req := js.Global.Get("XMLHttpRequest").New()
-	req.Call("open", "GET", r.url, true)
+	req.Call("open", "GET", url, true)
+	req.Set("responseType", responseType)
 	req.Call("addEventListener", "load", func(*js.Object) {
-		go func() { ch <- nil }()
+		go func() {
+			if status := req.Get("status").Int(); status >= 400 {
+				ch <- errors.New("engi: " + url + ": " + req.Get("statusText").String())
+				return
+			}
+			ch <- nil
+		}()
 	}, false)
 	req.Call("addEventListener", "error", func(o *js.Object) {
 		go func() { ch <- &js.Error{Object: o} }()
@@ -256,10 +327,65 @@ func loadJson(r Resource) (string, error) {
 
 	err := <-ch
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return req.Get("responseText").String(), nil
+	return req.Get("response"), nil
+}
+
+// defaultFS returns nil, leaving files to be fetched over http.
+func defaultFS() *FS {
+	return nil
+}
+
+func loadBytes(r Resource) ([]byte, error) {
+	res, err := request(r.url, "arraybuffer")
+	if err != nil {
+		return nil, err
+	}
+	return js.Global.Get("Uint8Array").New(res).Interface().([]byte), nil
+}
+
+func modTime(url string) (time.Time, error) {
+	return time.Time{}, errors.New("engi: cannot watch " + url)
+}
+
+// writeFile has the browser download data as a file called the last element
+// of name.
+func writeFile(name string, data []byte) error {
+	blob := js.Global.Get("Blob").New([]interface{}{data}, map[string]interface{}{"type": "application/octet-stream"})
+	url := js.Global.Get("URL").Call("createObjectURL", blob)
+
+	link := js.Global.Get("document").Call("createElement", "a")
+	link.Set("href", url)
+	link.Set("download", path.Base(name))
+	js.Global.Get("document").Get("body").Call("appendChild", link)
+	link.Call("click")
+	js.Global.Get("document").Get("body").Call("removeChild", link)
+
+	// Revoking the url straight away can cancel the download.
+	js.Global.Call("setTimeout", func() {
+		js.Global.Get("URL").Call("revokeObjectURL", url)
+	}, 1000)
+	return nil
+}
+
+// makeDir does nothing, as downloads go wherever the browser puts them.
+func makeDir(dir string) error {
+	return nil
+}
+
+func newImage(pixels *image.NRGBA) Image {
+	b := pixels.Bounds()
+	img := newBlankImage(b.Dx(), b.Dy()).(*ImageObject)
+	ctx := img.data.Call("getContext", "2d")
+	data := ctx.Call("createImageData", b.Dx(), b.Dy())
+	d := data.Get("data")
+	for i, p := range pixels.Pix {
+		d.SetIndex(i, p)
+	}
+	ctx.Call("putImageData", data, 0, 0)
+	return img
 }
 
 type ImageObject struct {
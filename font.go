@@ -58,3 +58,32 @@ func (f *Font) Print(batch *Batch, text string, x, y float32, color uint32) {
 		}
 	}
 }
+
+// PutColor is Put with a Color, which may be translucent.
+func (f *Font) PutColor(batch *Batch, r rune, x, y float32, c Color) {
+	if g, ok := f.glyphs[r]; ok {
+		batch.Draw(g.region, x+g.xoffset, y+g.yoffset, 0, 0, 1, 1, 0, c.Hex(), c.A)
+	}
+}
+
+// PrintColor is Print with a Color, which may be translucent.
+func (f *Font) PrintColor(batch *Batch, text string, x, y float32, c Color) {
+	xx := x
+	for _, r := range text {
+		if g, ok := f.glyphs[r]; ok {
+			batch.Draw(g.region, xx+g.xoffset, y+g.yoffset, 0, 0, 1, 1, 0, c.Hex(), c.A)
+			xx += g.xadvance
+		}
+	}
+}
+
+// lineHeight returns the height of the tallest glyph.
+func (f *Font) lineHeight() float32 {
+	var height float32
+	for _, g := range f.glyphs {
+		if h := g.region.Height() + g.yoffset; h > height {
+			height = h
+		}
+	}
+	return height
+}
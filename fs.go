@@ -0,0 +1,296 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source is somewhere files can be mounted from. Names are slash separated
+// and relative to the source.
+type Source interface {
+	Open(name string) (io.ReadCloser, error)
+}
+
+// FS layers mounted sources into a single tree of files. A file is read from
+// the most recently mounted source that has it, so a mod can be mounted over
+// the game's own files to override them. It is safe to use from several
+// goroutines.
+type FS struct {
+	mu     sync.RWMutex
+	mounts []mount
+}
+
+type mount struct {
+	prefix string
+	source Source
+}
+
+func NewFS() *FS {
+	return &FS{}
+}
+
+// Mount makes the files of source appear under prefix, which may be empty to
+// mount them at the root. It takes priority over every source mounted
+// before it.
+func (fs *FS) Mount(prefix string, source Source) {
+	prefix = cleanName(prefix)
+	if prefix != "" {
+		prefix += "/"
+	}
+	fs.mu.Lock()
+	fs.mounts = append(fs.mounts, mount{prefix, source})
+	fs.mu.Unlock()
+}
+
+// Unmount removes every mount of source.
+func (fs *FS) Unmount(source Source) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	// Build a new slice, since find may be reading the old one.
+	var mounts []mount
+	for _, m := range fs.mounts {
+		if !sameSource(m.source, source) {
+			mounts = append(mounts, m)
+		}
+	}
+	fs.mounts = mounts
+}
+
+// sameSource reports whether a and b are the same source. Maps and funcs
+// cannot be compared with ==, so they are compared by address.
+func sameSource(a, b Source) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Func:
+		return va.Pointer() == vb.Pointer()
+	}
+	return va.Type().Comparable() && a == b
+}
+
+// find calls fn with each source that could hold name, most recent first,
+// until fn returns something other than a not exist error.
+//
+// Names are cleaned into the FS's own tree, except that absolute paths and
+// those above the current directory are given as they are to directories
+// mounted at the root, which resolve them as the OS does.
+func (fs *FS) find(name string, fn func(Source, string) error) error {
+	raw, outside := name, outsideTree(name)
+	name = cleanName(name)
+
+	fs.mu.RLock()
+	mounts := fs.mounts
+	fs.mu.RUnlock()
+
+	for i := len(mounts) - 1; i >= 0; i-- {
+		m := mounts[i]
+		var err error
+		if _, dir := m.source.(DirSource); dir && outside {
+			if m.prefix != "" {
+				continue
+			}
+			err = fn(m.source, raw)
+		} else {
+			if !strings.HasPrefix(name, m.prefix) {
+				continue
+			}
+			err = fn(m.source, name[len(m.prefix):])
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return &os.PathError{Op: "open", Path: raw, Err: os.ErrNotExist}
+}
+
+// outsideTree reports whether name is an absolute path or one that climbs
+// above the directory it is relative to.
+func outsideTree(name string) bool {
+	if filepath.IsAbs(name) {
+		return true
+	}
+	name = path.Clean(filepath.ToSlash(name))
+	return name == ".." || strings.HasPrefix(name, "../")
+}
+
+// Open opens the file called name from the highest priority source that
+// has it.
+func (fs *FS) Open(name string) (io.ReadCloser, error) {
+	var file io.ReadCloser
+	err := fs.find(name, func(s Source, rel string) error {
+		var err error
+		file, err = s.Open(rel)
+		return err
+	})
+	return file, err
+}
+
+// ReadFile returns the contents of the file called name.
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+// ModTime returns when the file called name was last modified, if its
+// source can tell.
+func (fs *FS) ModTime(name string) (time.Time, error) {
+	var mtime time.Time
+	err := fs.find(name, func(s Source, rel string) error {
+		if d, ok := s.(DirSource); ok {
+			info, err := os.Stat(d.path(rel))
+			if err == nil {
+				mtime = info.ModTime()
+			}
+			return err
+		}
+		file, err := s.Open(rel)
+		if err == nil {
+			file.Close()
+			err = errors.New("engi: " + name + " has no modification time")
+		}
+		return err
+	})
+	return mtime, err
+}
+
+// Path returns where the file called name is on disk, for libraries that
+// can only open files by path. Files in anything but a directory have none.
+func (fs *FS) Path(name string) (string, error) {
+	var p string
+	err := fs.find(name, func(s Source, rel string) error {
+		if d, ok := s.(DirSource); ok {
+			p = d.path(rel)
+			_, err := os.Stat(p)
+			return err
+		}
+		file, err := s.Open(rel)
+		if err == nil {
+			file.Close()
+			err = errors.New("engi: " + name + " is not in a mounted directory")
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// cleanName makes name relative and removes any . and .. elements.
+func cleanName(name string) string {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	return strings.TrimPrefix(name, "/")
+}
+
+// DirSource is a directory on disk. Absolute names are opened as they are,
+// and names that climb out of it with .. are resolved from it.
+type DirSource string
+
+func (d DirSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(d.path(name))
+}
+
+func (d DirSource) path(name string) string {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(string(d), name)
+}
+
+// MapSource holds files in memory, by name. It is handy for tests and for
+// files generated at run time.
+type MapSource map[string][]byte
+
+func (m MapSource) Open(name string) (io.ReadCloser, error) {
+	data, ok := m[cleanName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// FuncSource reads files embedded in the program, such as by the Asset
+// function generated by go-bindata. An error for a missing file is treated
+// as the file not existing.
+type FuncSource func(name string) ([]byte, error)
+
+func (f FuncSource) Open(name string) (io.ReadCloser, error) {
+	data, err := f(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// ZipSource is a zip archive, which may have any extension, such as .pak.
+type ZipSource struct {
+	files  map[string]*zip.File
+	closer io.Closer
+}
+
+// OpenZip opens the zip archive at path. Close it once it is unmounted.
+func OpenZip(path string) (*ZipSource, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return newZipSource(&r.Reader, r), nil
+}
+
+// NewZipSource reads a zip archive of the given size from r, such as one
+// embedded in the program as a byte slice through a bytes.Reader.
+func NewZipSource(r io.ReaderAt, size int64) (*ZipSource, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return newZipSource(z, nil), nil
+}
+
+func newZipSource(r *zip.Reader, closer io.Closer) *ZipSource {
+	z := &ZipSource{make(map[string]*zip.File), closer}
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, "/") {
+			z.files[cleanName(f.Name)] = f
+		}
+	}
+	return z
+}
+
+func (z *ZipSource) Open(name string) (io.ReadCloser, error) {
+	f, ok := z.files[cleanName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return f.Open()
+}
+
+// Close closes the archive's file, if it was opened by OpenZip.
+func (z *ZipSource) Close() error {
+	if z.closer == nil {
+		return nil
+	}
+	return z.closer.Close()
+}
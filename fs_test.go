@@ -0,0 +1,139 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func zipSource(t *testing.T, files map[string]string) *ZipSource {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := NewZipSource(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func TestFS(t *testing.T) {
+	root, err := ioutil.TempDir("", "engi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	game := filepath.Join(root, "game")
+	for name, data := range map[string]string{
+		"game/data/hero.txt":  "dir hero",
+		"game/data/only.txt":  "dir only",
+		"shared/outside.txt":  "outside",
+		"shared/absolute.txt": "absolute",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := NewFS()
+	fs.Mount("", DirSource(game))
+	fs.Mount("", zipSource(t, map[string]string{
+		"data/hero.txt":   "zip hero",
+		"data/zipped.txt": "zipped",
+		"dir/":            "",
+	}))
+	fs.Mount("mods", MapSource{"data/hero.txt": []byte("mod hero")})
+	fs.Mount("", FuncSource(func(name string) ([]byte, error) {
+		if name == "embedded.txt" {
+			return []byte("embedded"), nil
+		}
+		return nil, errors.New("not found")
+	}))
+
+	tests := []struct {
+		name string
+		want string // empty if the file should not exist
+	}{
+		{"data/hero.txt", "zip hero"},
+		{"/data/hero.txt", "zip hero"},
+		{"data/./x/../hero.txt", "zip hero"},
+		{"data/only.txt", "dir only"},
+		{"data/zipped.txt", "zipped"},
+		{"mods/data/hero.txt", "mod hero"},
+		{"mods/data/only.txt", ""},
+		{"embedded.txt", "embedded"},
+		{"../shared/outside.txt", "outside"},
+		{"data/../../shared/outside.txt", "outside"},
+		{filepath.Join(root, "shared", "absolute.txt"), "absolute"},
+		{"dir", ""},
+		{"missing.txt", ""},
+	}
+
+	for _, tt := range tests {
+		data, err := fs.ReadFile(tt.name)
+		if tt.want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("ReadFile(%q) = %q, %v, want a not exist error", tt.name, data, err)
+			}
+			continue
+		}
+		if err != nil || string(data) != tt.want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q", tt.name, data, err, tt.want)
+		}
+	}
+
+	if p, err := fs.Path("data/only.txt"); err != nil || p != filepath.Join(game, "data", "only.txt") {
+		t.Errorf("Path of a file in a directory = %q, %v", p, err)
+	}
+	if p, err := fs.Path("data/zipped.txt"); err == nil {
+		t.Errorf("Path of a zipped file = %q, want an error", p)
+	}
+	if _, err := fs.ModTime("data/only.txt"); err != nil {
+		t.Errorf("ModTime of a file in a directory: %v", err)
+	}
+	if _, err := fs.ModTime("embedded.txt"); err == nil {
+		t.Error("ModTime of an embedded file should fail")
+	}
+}
+
+func TestFSUnmount(t *testing.T) {
+	base := MapSource{"a.txt": []byte("base")}
+	mod := MapSource{"a.txt": []byte("mod")}
+
+	fs := NewFS()
+	fs.Mount("", base)
+	fs.Mount("", mod)
+	if data, _ := fs.ReadFile("a.txt"); string(data) != "mod" {
+		t.Errorf("read %q before unmounting, want the mod", data)
+	}
+
+	fs.Unmount(mod)
+	if data, _ := fs.ReadFile("a.txt"); string(data) != "base" {
+		t.Errorf("read %q after unmounting, want the base", data)
+	}
+
+	fs.Unmount(base)
+	if _, err := fs.ReadFile("a.txt"); !os.IsNotExist(err) {
+		t.Errorf("read with nothing mounted gave %v, want a not exist error", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JsonError describes why a json resource could not be decoded, naming the
+// resource and, where known, the field and position at fault.
+type JsonError struct {
+	Name   string
+	Field  string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *JsonError) Error() string {
+	msg := fmt.Sprintf("engi: json %q", e.Name)
+	if e.Field != "" {
+		msg += fmt.Sprintf(" field %q", e.Field)
+	}
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" at line %d, column %d", e.Line, e.Column)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Validator is implemented by values that check themselves once decoded by
+// DecodeJson. Returning a *JsonError with Field set points at the field.
+type Validator interface {
+	Validate() error
+}
+
+type decodedKey struct {
+	name   string
+	typ    reflect.Type
+	strict bool
+}
+
+// DecodeJson decodes the json resource called name into v, which must be a
+// pointer. Decoded values are cached by name and type, so decoding the same
+// resource again copies the cached value; slices and maps in it are shared
+// between calls.
+//
+// Struct fields tagged engi:"required" must be present. If StrictJson is
+// set, fields that v does not have are errors. If v implements Validator,
+// Validate is called after decoding.
+func (l *Loader) DecodeJson(name string, v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return &JsonError{Name: name, Err: errors.New("DecodeJson needs a non-nil pointer")}
+	}
+
+	key := decodedKey{name, ptr.Type(), l.StrictJson}
+	if cached, ok := l.decoded[key]; ok {
+		ptr.Elem().Set(cached)
+		return nil
+	}
+
+	data, ok := l.jsons[name]
+	if !ok {
+		return &JsonError{Name: name, Err: errors.New("not loaded")}
+	}
+
+	if err := decodeJson(name, data, v, l.StrictJson); err != nil {
+		return err
+	}
+
+	cached := reflect.New(ptr.Type().Elem()).Elem()
+	cached.Set(ptr.Elem())
+	l.decoded[key] = cached
+	return nil
+}
+
+func decodeJson(name, data string, v interface{}, strict bool) error {
+	dec := json.NewDecoder(strings.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return jsonError(name, data, err)
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return jsonError(name, data, err)
+	}
+	if err := checkRequired(raw, reflect.TypeOf(v), ""); err != nil {
+		err.Name = name
+		return err
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			if jerr, ok := err.(*JsonError); ok {
+				jerr.Name = name
+				return jerr
+			}
+			return &JsonError{Name: name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// jsonError converts an error from encoding/json into a *JsonError.
+func jsonError(name, data string, err error) *JsonError {
+	jerr := &JsonError{Name: name, Err: err}
+	var offset int64 = -1
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+		jerr.Field = e.Field
+		jerr.Err = fmt.Errorf("cannot use %s as %s", e.Value, e.Type)
+	}
+	if offset >= 0 && offset <= int64(len(data)) {
+		before := []byte(data[:offset])
+		jerr.Line = bytes.Count(before, []byte("\n")) + 1
+		jerr.Column = len(before) - bytes.LastIndexByte(before, '\n') - 1
+	}
+	return jerr
+}
+
+// checkRequired walks raw, the generic decoding of a json value, alongside
+// the type it was decoded into, reporting the first struct field tagged
+// engi:"required" that is missing.
+func checkRequired(raw interface{}, t reflect.Type, path string) *JsonError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if embedded(f) {
+				// The fields of an embedded struct are read from the
+				// same object, as encoding/json promotes them.
+				if err := checkRequired(raw, f.Type, path); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			key := jsonKey(f)
+			if key == "-" {
+				continue
+			}
+			value, found := lookupKey(obj, key)
+			field := joinField(path, key)
+			if !found {
+				if f.Tag.Get("engi") == "required" {
+					return &JsonError{Field: field, Err: errors.New("required field is missing")}
+				}
+				continue
+			}
+			if err := checkRequired(value, f.Type, field); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, value := range list {
+			if err := checkRequired(value, t.Elem(), joinField(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for key, value := range obj {
+			if err := checkRequired(value, t.Elem(), joinField(path, key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// embedded reports whether f is an embedded struct whose fields
+// encoding/json treats as fields of the outer struct.
+func embedded(f reflect.StructField) bool {
+	if !f.Anonymous {
+		return false
+	}
+	if tag := f.Tag.Get("json"); tag != "" && !strings.HasPrefix(tag, ",") {
+		return false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		if f.PkgPath != "" {
+			return false
+		}
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func jsonKey(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return f.Name
+	}
+	return tag
+}
+
+// lookupKey finds key in obj the way encoding/json matches fields, falling
+// back to a case-insensitive match.
+func lookupKey(obj map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := obj[key]; ok {
+		return value, true
+	}
+	for k, value := range obj {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func joinField(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,104 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type jsonStats struct {
+	HP    int `json:"hp" engi:"required"`
+	Speed int `json:"speed"`
+}
+
+type jsonNamed struct {
+	Name string `json:"name" engi:"required"`
+}
+
+type jsonEnemy struct {
+	jsonNamed
+	jsonStats
+	Drops []jsonNamed `json:"drops"`
+}
+
+type jsonLevel struct {
+	Title   string               `json:"title" engi:"required"`
+	Enemies []jsonEnemy          `json:"enemies"`
+	Spawns  map[string]jsonStats `json:"spawns"`
+	Boss    *jsonEnemy           `json:"boss"`
+	Named   jsonNamed            `json:"named"`
+}
+
+func TestCheckRequired(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		field string // the missing field, or empty if there is none
+	}{
+		{"complete", `{"title": "one"}`, ""},
+		{"missing top level", `{}`, "title"},
+		{"case insensitive key", `{"TITLE": "one"}`, ""},
+		{"slice element", `{"title": "one", "enemies": [{"name": "a", "hp": 1}, {"name": "b"}]}`, "enemies.1.hp"},
+		{"embedded struct", `{"title": "one", "enemies": [{"hp": 1}]}`, "enemies.0.name"},
+		{"second embedded struct", `{"title": "one", "boss": {"name": "a"}}`, "boss.hp"},
+		{"embedded complete", `{"title": "one", "boss": {"name": "a", "hp": 3}}`, ""},
+		{"nested slice", `{"title": "one", "boss": {"name": "a", "hp": 3, "drops": [{}]}}`, "boss.drops.0.name"},
+		{"map value", `{"title": "one", "spawns": {"north": {"speed": 2}}}`, "spawns.north.hp"},
+		{"nested struct", `{"title": "one", "named": {}}`, "named.name"},
+		{"null is present", `{"title": null}`, ""},
+	}
+
+	for _, tt := range tests {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(tt.data), &raw); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err := checkRequired(raw, reflect.TypeOf(&jsonLevel{}), "")
+		switch {
+		case tt.field == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.field != "" && err == nil:
+			t.Errorf("%s: expected %s to be missing", tt.name, tt.field)
+		case err != nil && err.Field != tt.field:
+			t.Errorf("%s: missing field is %s, want %s", tt.name, err.Field, tt.field)
+		}
+	}
+}
+
+func TestDecodeJsonErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		strict       bool
+		field        string
+		line, column int
+	}{
+		{"syntax", "{\n  \"title\": \"one\",\n}", false, "", 3, 1},
+		{"type", "{\"title\": 5}", false, "title", 1, 11},
+		{"unknown field strict", `{"title": "one", "extra": 1}`, true, "", 0, 0},
+		{"missing", `{"named": {}}`, false, "title", 0, 0},
+	}
+
+	for _, tt := range tests {
+		var level jsonLevel
+		err := decodeJson("level", tt.data, &level, tt.strict)
+		jerr, ok := err.(*JsonError)
+		if !ok {
+			t.Errorf("%s: got %v, want a *JsonError", tt.name, err)
+			continue
+		}
+		if jerr.Name != "level" || jerr.Field != tt.field || jerr.Line != tt.line || jerr.Column != tt.column {
+			t.Errorf("%s: got %q %q %d:%d, want %q %q %d:%d", tt.name,
+				jerr.Name, jerr.Field, jerr.Line, jerr.Column, "level", tt.field, tt.line, tt.column)
+		}
+	}
+
+	var level jsonLevel
+	if err := decodeJson("level", `{"title": "one", "extra": 1}`, &level, false); err != nil {
+		t.Errorf("unknown field without strict: %v", err)
+	}
+}
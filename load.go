@@ -0,0 +1,370 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"time"
+)
+
+const (
+	// loadWorkers is the number of resources of a Load read at once.
+	loadWorkers = 4
+	// uploadBudget limits how long update spends storing loaded resources
+	// each frame, so large loads do not stall rendering.
+	uploadBudget = 8 * time.Millisecond
+)
+
+// LoaderFunc decodes the contents of the resource called name. It is called
+// on a background goroutine, so it must not use the graphics context.
+type LoaderFunc func(name string, data []byte) (interface{}, error)
+
+var loaders = make(map[string]LoaderFunc)
+
+// RegisterLoader makes resources of kind load through fn, with the value it
+// returns available from Loader.Get. Registering a built-in kind replaces
+// it. Use RegisterExtension to have Add recognise files of the kind. Both
+// must be called before loading starts, such as from init or Preload.
+func RegisterLoader(kind string, fn LoaderFunc) {
+	loaders[kind] = fn
+}
+
+// loadJob is a single call to Load that has not yet finished.
+type loadJob struct {
+	results  chan loadResult
+	cancel   chan struct{}
+	progress Progress
+	errors   []*ResourceError
+	failed   bool
+	reload   bool
+	fs       *FS
+	onFinish func()
+	// queued names the resources the job reads itself, and waiting those
+	// it left to earlier jobs already reading them.
+	queued  []string
+	waiting []string
+}
+
+type loadResult struct {
+	resource Resource
+	value    interface{}
+	bytes    int
+	err      error
+}
+
+// Load starts loading every queued resource and returns immediately. Files
+// are read and decoded in the background, while textures and sounds are
+// created on the render thread a few at a time each frame. Once all of them
+// have finished, onFinish is called on the render thread.
+//
+// Resources that fail are recorded in Errors rather than stopping the rest,
+// unless FailFast is set. Load may be called again, even while an earlier
+// Load is running, to load resources added since. A resource whose name is
+// already loaded, or being loaded, is not loaded again; it gains a reference
+// instead.
+func (l *Loader) Load(onFinish func()) {
+	resources := l.resources
+	l.resources = nil
+	l.start(resources, false, onFinish)
+}
+
+// start begins a job loading resources. Unless the job reloads changed
+// files, resources already loaded gain a reference and those being loaded
+// by another job are waited for rather than read twice.
+func (l *Loader) start(resources []Resource, reload bool, onFinish func()) *loadJob {
+	job := &loadJob{
+		cancel:   make(chan struct{}),
+		reload:   reload,
+		fs:       l.FS,
+		onFinish: onFinish,
+	}
+
+	queue := make(chan Resource, len(resources))
+	for _, r := range resources {
+		if !reload {
+			if _, ok := l.assets[r.name]; ok {
+				l.refs[r.name]++
+				continue
+			}
+			if _, ok := l.pending[r.name]; ok {
+				l.pending[r.name]++
+				job.waiting = append(job.waiting, r.name)
+				continue
+			}
+			l.pending[r.name] = 1
+		}
+		job.queued = append(job.queued, r.name)
+		queue <- r
+	}
+	close(queue)
+
+	job.results = make(chan loadResult, len(job.queued))
+	job.progress.Total = len(job.queued)
+
+	for i := 0; i < loadWorkers && i < job.progress.Total; i++ {
+		go job.work(queue)
+	}
+	l.jobs = append(l.jobs, job)
+	return job
+}
+
+// Loading reports whether any Load has yet to finish.
+func (l *Loader) Loading() bool {
+	for _, job := range l.jobs {
+		if !job.reload {
+			return true
+		}
+	}
+	return false
+}
+
+// Progress returns the combined progress of every Load that has yet to
+// finish.
+func (l *Loader) Progress() Progress {
+	var p Progress
+	for _, job := range l.jobs {
+		if job.reload {
+			continue
+		}
+		p.Loaded += job.progress.Loaded
+		p.Total += job.progress.Total
+		p.Bytes += job.progress.Bytes
+		p.Name = job.progress.Name
+	}
+	return p
+}
+
+func (j *loadJob) work(queue <-chan Resource) {
+	for r := range queue {
+		select {
+		case <-j.cancel:
+			return
+		default:
+		}
+		value, n, err := read(j.fs, r)
+		j.results <- loadResult{r, value, n, err}
+	}
+}
+
+// update is called by the backends every frame to store the resources read
+// since the last frame and finish any loads that are done.
+func (l *Loader) update() {
+	deadline := time.Now().Add(uploadBudget)
+
+	// A job has read everything once receive returns true, but it may
+	// still be waiting on resources left to other jobs.
+	for _, job := range l.jobs {
+		if job.queued == nil || !l.receive(job, deadline) {
+			continue
+		}
+		if job.failed && !job.reload {
+			// Resources the job never read are no longer being loaded.
+			for _, name := range job.queued {
+				delete(l.pending, name)
+			}
+		}
+		job.queued = nil
+	}
+
+	var finished []*loadJob
+	active := l.jobs[:0]
+	for _, job := range l.jobs {
+		if job.queued == nil && !l.waitingOn(job) {
+			finished = append(finished, job)
+		} else {
+			active = append(active, job)
+		}
+	}
+	l.jobs = active
+
+	for _, job := range finished {
+		if job.reload {
+			for _, err := range job.errors {
+				log.Print(err)
+			}
+			continue
+		}
+		l.errors = job.errors
+		job.onFinish()
+	}
+
+	if l.watch != nil {
+		l.poll()
+	}
+}
+
+// waitingOn reports whether any resource job left to another job is still
+// being loaded.
+func (l *Loader) waitingOn(job *loadJob) bool {
+	for _, name := range job.waiting {
+		if _, ok := l.pending[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// receive stores the results of job that are ready, until deadline passes,
+// and reports whether the job has finished.
+func (l *Loader) receive(job *loadJob, deadline time.Time) bool {
+	for !job.failed && job.progress.Loaded < job.progress.Total {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		var res loadResult
+		select {
+		case res = <-job.results:
+		default:
+			return false
+		}
+
+		r := res.resource
+		err := res.err
+		if err == nil && job.reload {
+			if _, ok := l.assets[r.name]; ok {
+				err = l.replace(r, res.value)
+				if reloader, ok := responder.(Reloader); ok && err == nil {
+					reloader.Reload(r.name)
+				}
+			}
+		} else if !job.reload {
+			if err == nil {
+				err = l.store(r, res.value)
+			}
+			if err == nil {
+				l.refs[r.name] += l.pending[r.name]
+				l.sources[r.name] = r
+			}
+			delete(l.pending, r.name)
+		}
+		if err != nil {
+			job.errors = append(job.errors, &ResourceError{res.resource.name, res.resource.url, err})
+			if l.FailFast {
+				job.failed = true
+				close(job.cancel)
+			}
+		}
+
+		job.progress.Loaded++
+		job.progress.Bytes += int64(res.bytes)
+		job.progress.Name = res.resource.name
+		if l.OnProgress != nil && !job.reload {
+			l.OnProgress(job.progress)
+		}
+	}
+	return true
+}
+
+// read does the part of loading a resource that is safe off the render
+// thread, returning the decoded value and the number of bytes read. Files
+// are read through fs, or by the backend if fs is nil.
+func read(fs *FS, r Resource) (interface{}, int, error) {
+	fetch := func() ([]byte, error) {
+		if fs != nil {
+			return fs.ReadFile(r.url)
+		}
+		return loadBytes(r)
+	}
+
+	if fn, ok := loaders[r.kind]; ok {
+		data, err := fetch()
+		if err != nil {
+			return nil, 0, err
+		}
+		value, err := fn(r.name, data)
+		return value, len(data), err
+	}
+
+	switch r.kind {
+	case "image", "ninepatch":
+		if fs == nil && extension(r.url) != "tga" {
+			img, n, err := loadImage(r)
+			return img, n, err
+		}
+		data, err := fetch()
+		if err != nil {
+			return nil, 0, err
+		}
+		pixels, err := decodeImage(r.url, data)
+		if err != nil {
+			return nil, len(data), err
+		}
+		return newImage(pixels), len(data), nil
+	case "json", "text", "xml", "shader":
+		data, err := fetch()
+		return string(data), len(data), err
+	case "binary":
+		data, err := fetch()
+		return data, len(data), err
+	case "sound":
+		// Players own audio device buffers, so they are opened by store,
+		// from the file's samples or, without an FS, its url.
+		if fs == nil {
+			return r.url, 0, nil
+		}
+		data, err := fs.ReadFile(r.url)
+		if err != nil {
+			return nil, 0, err
+		}
+		sound, err := decodeSound(r.url, data)
+		if err != nil {
+			return nil, len(data), err
+		}
+		return sound, len(data), nil
+	case "":
+		return nil, 0, fmt.Errorf("no resource kind for extension %q, use AddAs", extension(r.url))
+	}
+	return nil, 0, fmt.Errorf("unknown resource kind %q", r.kind)
+}
+
+// knownKind reports whether resources of kind can be loaded.
+func knownKind(kind string) bool {
+	switch kind {
+	case "image", "ninepatch", "json", "sound", "text", "xml", "binary", "shader":
+		return true
+	}
+	_, ok := loaders[kind]
+	return ok
+}
+
+// decodeImage decodes the contents of the image file at url.
+func decodeImage(url string, data []byte) (*image.NRGBA, error) {
+	if extension(url) == "tga" {
+		return decodeTGA(data)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return nrgba(img), nil
+}
+
+// store finishes loading a resource on the render thread.
+func (l *Loader) store(r Resource, value interface{}) error {
+	if _, ok := loaders[r.kind]; !ok {
+		switch r.kind {
+		case "image":
+			value = NewTexture(value.(Image), r.options)
+		case "ninepatch":
+			patch, err := NewNinePatchImage(value.(Image), r.options)
+			if err != nil {
+				return err
+			}
+			value = patch
+		case "sound":
+			player, err := newPlayer(value)
+			if err != nil {
+				return err
+			}
+			value = &Sound{player}
+		}
+	}
+	l.put(r.name, r.kind, value)
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Manifest lists resources to load, read from JSON such as
+//
+//	{"assets": [
+//		{"name": "font", "url": "data/font.png", "preset": "pixel"},
+//		{"name": "sky", "url": "data/sky.png", "options": {"wrapS": "repeat"}},
+//		{"name": "hit", "url": "data/hit.wav", "groups": ["level1"]},
+//		{"name": "map", "url": "levels/1", "kind": "json", "groups": ["level1"]}
+//	]}
+type Manifest struct {
+	Assets []ManifestAsset `json:"assets" engi:"required"`
+}
+
+// ManifestAsset is a single resource of a manifest.
+type ManifestAsset struct {
+	Name string `json:"name" engi:"required"`
+	URL  string `json:"url" engi:"required"`
+	// Kind is found from the extension of URL if it is empty.
+	Kind string `json:"kind"`
+	// Preset is the texture options to start from, default or pixel, and
+	// Options holds TextureOptions fields that override it.
+	Preset  string          `json:"preset"`
+	Options json.RawMessage `json:"options"`
+	// Groups names the bundles the resource belongs to. Resources in no
+	// group are queued on the Loader itself.
+	Groups []string `json:"groups"`
+}
+
+// ManifestError lists everything wrong with a manifest.
+type ManifestError struct {
+	Errors []error
+}
+
+func (e *ManifestError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+	return fmt.Sprintf("%d problems: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// ParseManifest reads a manifest from data, checking that every asset has a
+// unique name and a url, neither empty, a kind that can be loaded and valid
+// texture options. Errors are *JsonErrors naming the manifest as name.
+func ParseManifest(name string, data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := decodeJson(name, string(data), &m, true); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate is called by ParseManifest once the manifest is decoded.
+func (m *Manifest) Validate() error {
+	var errs []error
+	names := make(map[string]bool)
+	for i, a := range m.Assets {
+		field := fmt.Sprintf("assets.%d", i)
+		if a.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is empty", field))
+		}
+		if a.URL == "" {
+			errs = append(errs, fmt.Errorf("%s: url is empty", field))
+		}
+		if a.Name != "" && names[a.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate name %q", field, a.Name))
+		}
+		names[a.Name] = true
+
+		if kind := a.kind(); kind == "" {
+			if a.URL != "" {
+				errs = append(errs, fmt.Errorf("%s: no kind for extension %q", field, extension(a.URL)))
+			}
+		} else if !knownKind(kind) {
+			errs = append(errs, fmt.Errorf("%s: unknown kind %q", field, kind))
+		}
+
+		if _, err := a.options(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", field, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ManifestError{errs}
+	}
+	return nil
+}
+
+func (a *ManifestAsset) kind() string {
+	if a.Kind != "" {
+		return a.Kind
+	}
+	return extensions[extension(a.URL)]
+}
+
+func (a *ManifestAsset) options() (TextureOptions, error) {
+	var options TextureOptions
+	switch a.Preset {
+	case "", "default":
+		options = DefaultTextureOptions
+	case "pixel":
+		options = PixelTextureOptions
+	default:
+		return options, fmt.Errorf("unknown preset %q", a.Preset)
+	}
+
+	if len(a.Options) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(a.Options))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&options); err != nil {
+			return options, fmt.Errorf("options: %v", err)
+		}
+	}
+
+	return options, nil
+}
+
+// AddManifest queues the assets of m, those in groups going to the bundles
+// of the same names. If FS is set, every file is first checked to exist;
+// if any are missing nothing is queued and a *ManifestError lists them.
+// Only the desktop backend sets FS by default, so on the web missing files
+// are instead reported by Load.
+func (l *Loader) AddManifest(m *Manifest) error {
+	if l.FS != nil {
+		var errs []error
+		for _, a := range m.Assets {
+			file, err := l.FS.Open(a.URL)
+			if err != nil {
+				errs = append(errs, &ResourceError{a.Name, a.URL, err})
+				continue
+			}
+			file.Close()
+		}
+		if len(errs) > 0 {
+			return &ManifestError{errs}
+		}
+	}
+
+	for _, a := range m.Assets {
+		options, err := a.options()
+		if err != nil {
+			return &ResourceError{a.Name, a.URL, err}
+		}
+		if len(a.Groups) == 0 {
+			l.AddAs(a.kind(), a.Name, a.URL, options)
+		}
+		for _, group := range a.Groups {
+			l.Bundle(group).AddAs(a.kind(), a.Name, a.URL, options)
+		}
+	}
+
+	return nil
+}
+
+// LoadManifest reads, checks and queues the manifest at url, as
+// ParseManifest and AddManifest do. It blocks, so on the web it must be
+// called from a goroutine.
+func (l *Loader) LoadManifest(url string) error {
+	var data []byte
+	var err error
+	if l.FS != nil {
+		data, err = l.FS.ReadFile(url)
+	} else {
+		data, err = loadBytes(Resource{kind: "json", url: url})
+	}
+	if err != nil {
+		return &ResourceError{"manifest", url, err}
+	}
+
+	m, err := ParseManifest(url, data)
+	if err != nil {
+		return err
+	}
+	return l.AddManifest(m)
+}
@@ -0,0 +1,115 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string // a fragment of the expected error, or empty
+	}{
+		{"valid", `{"assets": [
+			{"name": "font", "url": "data/font.png", "preset": "pixel"},
+			{"name": "sky", "url": "data/sky.png", "options": {"wrapS": "repeat"}},
+			{"name": "map", "url": "levels/1", "kind": "json", "groups": ["level1"]}
+		]}`, ""},
+		{"empty list", `{"assets": []}`, ""},
+		{"syntax", `{"assets": [}`, "invalid character"},
+		{"no assets", `{}`, `field "assets": required field is missing`},
+		{"unknown field", `{"assets": [], "extra": 1}`, "unknown field"},
+		{"no name", `{"assets": [{"url": "a.png"}]}`, `field "assets.0.name": required`},
+		{"no url", `{"assets": [{"name": "a"}]}`, `field "assets.0.url": required`},
+		{"empty name", `{"assets": [{"name": "", "url": "a.png"}]}`, "assets.0: name is empty"},
+		{"empty url", `{"assets": [{"name": "a", "url": ""}]}`, "assets.0: url is empty"},
+		{"duplicate", `{"assets": [{"name": "a", "url": "a.png"}, {"name": "a", "url": "b.png"}]}`, `assets.1: duplicate name "a"`},
+		{"no kind", `{"assets": [{"name": "a", "url": "a.xyz"}]}`, `no kind for extension "xyz"`},
+		{"unknown kind", `{"assets": [{"name": "a", "url": "a", "kind": "model"}]}`, `unknown kind "model"`},
+		{"unknown preset", `{"assets": [{"name": "a", "url": "a.png", "preset": "blurry"}]}`, `unknown preset "blurry"`},
+		{"bad option", `{"assets": [{"name": "a", "url": "a.png", "options": {"wrapS": "sideways"}}]}`, "options:"},
+		{"unknown option", `{"assets": [{"name": "a", "url": "a.png", "options": {"wrap": "repeat"}}]}`, "options:"},
+		{"several problems", `{"assets": [{"name": "a", "url": "a.xyz"}, {"name": "a", "url": ""}]}`, "3 problems"},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseManifest("manifest.json", []byte(tt.data))
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if m == nil {
+				t.Errorf("%s: no manifest returned", tt.name)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error containing %q", tt.name, tt.err)
+			continue
+		}
+		if _, ok := err.(*JsonError); !ok {
+			t.Errorf("%s: got %T, want a *JsonError", tt.name, err)
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %q does not contain %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestManifestOptions(t *testing.T) {
+	m, err := ParseManifest("manifest.json", []byte(`{"assets": [
+		{"name": "a", "url": "a.png", "preset": "pixel", "options": {"wrapS": "repeat"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := m.Assets[0].options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PixelTextureOptions
+	want.WrapS = WrapRepeat
+	if options != want {
+		t.Errorf("got options %+v, want %+v", options, want)
+	}
+}
+
+func TestAddManifest(t *testing.T) {
+	m, err := ParseManifest("manifest.json", []byte(`{"assets": [
+		{"name": "intro", "url": "intro.txt"},
+		{"name": "map", "url": "map.json", "groups": ["level1", "level2"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLoader()
+	l.FS = NewFS()
+	l.FS.Mount("", MapSource{"intro.txt": []byte("hi")})
+	err = l.AddManifest(m)
+	merr, ok := err.(*ManifestError)
+	if !ok || len(merr.Errors) != 1 || !strings.Contains(err.Error(), "map.json") {
+		t.Fatalf("got %v, want map.json reported missing", err)
+	}
+	if len(l.resources) != 0 || len(l.bundles) != 0 {
+		t.Error("resources were queued from a manifest with missing files")
+	}
+
+	l.FS.Mount("", MapSource{"map.json": []byte("{}")})
+	if err := l.AddManifest(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.resources) != 1 || l.resources[0].name != "intro" || l.resources[0].kind != "text" {
+		t.Errorf("loader queued %+v, want intro as text", l.resources)
+	}
+	for _, group := range []string{"level1", "level2"} {
+		b := l.bundles[group]
+		if b == nil || len(b.resources) != 1 || b.resources[0].name != "map" {
+			t.Errorf("bundle %s does not hold map", group)
+		}
+	}
+}
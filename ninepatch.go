@@ -0,0 +1,162 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"errors"
+	"image"
+)
+
+// NinePatch draws a region at any size while keeping its corners intact.
+// The insets split the region into a three by three grid: the corners are
+// drawn as is, the edges are stretched or tiled along one axis, and the
+// center is stretched or tiled along both.
+type NinePatch struct {
+	Region                   *Region
+	Left, Top, Right, Bottom float32
+	TileEdges                bool
+	TileCenter               bool
+}
+
+// NewNinePatch creates a nine-patch from region and its insets in pixels.
+func NewNinePatch(region *Region, left, top, right, bottom int) *NinePatch {
+	return &NinePatch{
+		Region: region,
+		Left:   float32(left),
+		Top:    float32(top),
+		Right:  float32(right),
+		Bottom: float32(bottom),
+	}
+}
+
+// NewNinePatchImage creates a nine-patch from an Android style .9.png image,
+// whose one pixel border marks the stretchable area with black pixels along
+// the top and left edges. Only the first and last marked pixel of each edge
+// are used.
+func NewNinePatchImage(img Image, opts ...TextureOptions) (*NinePatch, error) {
+	pixels, err := imagePixels(img)
+	if err != nil {
+		return nil, err
+	}
+
+	left, top, right, bottom, err := ninePatchInsets(pixels)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := pixels.Rect.Dx(), pixels.Rect.Dy()
+	texture := NewTexture(img, opts...)
+	region := NewRegion(texture, 1, 1, w-2, h-2)
+
+	return NewNinePatch(region, left, top, right, bottom), nil
+}
+
+// ninePatchInsets reads the insets of the image inside the marker border
+// of a .9.png.
+func ninePatchInsets(pixels *image.NRGBA) (left, top, right, bottom int, err error) {
+	w, h := pixels.Rect.Dx(), pixels.Rect.Dy()
+	if w < 3 || h < 3 {
+		return 0, 0, 0, 0, errors.New("engi: nine-patch image is too small")
+	}
+
+	first, end, ok := ninePatchMarkers(pixels, w, func(i int) (int, int) { return i, 0 })
+	if !ok {
+		return 0, 0, 0, 0, errors.New("engi: nine-patch image has no top markers")
+	}
+	left, right = first-1, w-1-end
+
+	first, end, ok = ninePatchMarkers(pixels, h, func(i int) (int, int) { return 0, i })
+	if !ok {
+		return 0, 0, 0, 0, errors.New("engi: nine-patch image has no left markers")
+	}
+	top, bottom = first-1, h-1-end
+
+	return left, top, right, bottom, nil
+}
+
+// ninePatchMarkers scans the border pixels 1 to n-2 along one edge and
+// returns the first marked pixel and the one after the last.
+func ninePatchMarkers(img *image.NRGBA, n int, at func(int) (int, int)) (int, int, bool) {
+	first, last := -1, -1
+	for i := 1; i < n-1; i++ {
+		x, y := at(i)
+		c := img.NRGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+		if c.A > 127 && c.R < 64 && c.G < 64 && c.B < 64 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last + 1, first >= 0
+}
+
+// DrawColor is Draw with a Color, which may be translucent.
+func (n *NinePatch) DrawColor(batch *Batch, x, y, w, h float32, c Color) {
+	n.Draw(batch, x, y, w, h, c.Hex(), c.A)
+}
+
+func (n *NinePatch) Width() float32 {
+	return n.Region.Width()
+}
+
+func (n *NinePatch) Height() float32 {
+	return n.Region.Height()
+}
+
+// Draw draws the nine-patch into the rectangle x, y, w, h. If the rectangle
+// is smaller than the insets, the corners are shrunk to fit.
+func (n *NinePatch) Draw(batch *Batch, x, y, w, h float32, color uint32, transparency float32) {
+	tint := packColor(color, transparency)
+	r := n.Region
+	rw, rh := r.Width(), r.Height()
+
+	left, right := fitInsets(n.Left, n.Right, w)
+	top, bottom := fitInsets(n.Top, n.Bottom, h)
+
+	src := [2][4]float32{
+		{0, n.Left, rw - n.Right, rw},
+		{0, n.Top, rh - n.Bottom, rh},
+	}
+	dst := [2][4]float32{
+		{x, x + left, x + w - right, x + w},
+		{y, y + top, y + h - bottom, y + h},
+	}
+
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 3; i++ {
+			sx, sw := src[0][i], src[0][i+1]-src[0][i]
+			sy, sh := src[1][j], src[1][j+1]-src[1][j]
+			dx, dw := dst[0][i], dst[0][i+1]-dst[0][i]
+			dy, dh := dst[1][j], dst[1][j+1]-dst[1][j]
+
+			center := i == 1 && j == 1
+			edge := (i == 1) != (j == 1)
+
+			switch {
+			case center && n.TileCenter, edge && n.TileEdges:
+				tw, th := sw, sh
+				if i != 1 {
+					tw = dw
+				}
+				if j != 1 {
+					th = dh
+				}
+				batch.tileSub(r, sx, sy, sw, sh, tw, th, dx, dy, dw, dh, 0, 0, tint)
+			default:
+				batch.drawSub(r, sx, sy, sw, sh, dx, dy, dw, dh, tint)
+			}
+		}
+	}
+}
+
+// fitInsets scales a pair of insets down if they don't fit in size.
+func fitInsets(a, b, size float32) (float32, float32) {
+	if a+b <= size || a+b == 0 {
+		return a, b
+	}
+	scale := size / (a + b)
+	return a * scale, b * scale
+}
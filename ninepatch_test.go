@@ -0,0 +1,62 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ninePatchFixture builds a w by h .9.png image with the top edge marked
+// from x0 to x1 and the left edge from y0 to y1, both inclusive.
+func ninePatchFixture(w, h, x0, x1, y0, y1 int, origin image.Point) *image.NRGBA {
+	img := image.NewNRGBA(image.Rectangle{origin, origin.Add(image.Pt(w, h))})
+	black := color.NRGBA{0, 0, 0, 255}
+	for x := x0; x <= x1 && x0 > 0; x++ {
+		img.SetNRGBA(origin.X+x, origin.Y, black)
+	}
+	for y := y0; y <= y1 && y0 > 0; y++ {
+		img.SetNRGBA(origin.X, origin.Y+y, black)
+	}
+	return img
+}
+
+func TestNinePatchInsets(t *testing.T) {
+	tests := []struct {
+		name                     string
+		img                      *image.NRGBA
+		left, top, right, bottom int
+		err                      bool
+	}{
+		// A 10x8 image holds 8x6 content; marking content pixels 2-4 by
+		// 1-3 leaves 2 left, 3 right, 1 top and 2 bottom.
+		{"centered", ninePatchFixture(10, 8, 3, 5, 2, 4, image.Point{}), 2, 1, 3, 2, false},
+		{"whole edge", ninePatchFixture(10, 8, 1, 8, 1, 6, image.Point{}), 0, 0, 0, 0, false},
+		{"single pixel", ninePatchFixture(5, 5, 2, 2, 2, 2, image.Point{}), 1, 1, 1, 1, false},
+		{"offset bounds", ninePatchFixture(10, 8, 3, 5, 2, 4, image.Pt(7, 9)), 2, 1, 3, 2, false},
+		{"no top markers", ninePatchFixture(10, 8, 0, 0, 2, 4, image.Point{}), 0, 0, 0, 0, true},
+		{"no left markers", ninePatchFixture(10, 8, 3, 5, 0, 0, image.Point{}), 0, 0, 0, 0, true},
+		{"too small", ninePatchFixture(2, 8, 1, 1, 1, 1, image.Point{}), 0, 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		left, top, right, bottom, err := ninePatchInsets(tt.img)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if left != tt.left || top != tt.top || right != tt.right || bottom != tt.bottom {
+			t.Errorf("%s: got insets %d %d %d %d, want %d %d %d %d", tt.name,
+				left, top, right, bottom, tt.left, tt.top, tt.right, tt.bottom)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"log"
+
+	"github.com/ajhager/webgl"
+)
+
+// Effect is a full-screen shader pass run by a PostProcessor. Its fragment
+// shader samples the previous pass from uf_Texture at var_TexCoords, and is
+// also given uf_Resolution in pixels and uf_Time in seconds.
+type Effect struct {
+	Enabled    bool
+	shader     *webgl.Program
+	inPosition int
+	uniforms   map[string]*webgl.UniformLocation
+	params     map[string][]float32
+	names      []string
+	textures   map[string]*Texture
+	samplers   []string
+}
+
+// NewEffect compiles fragSrc into an enabled effect.
+func NewEffect(fragSrc string) *Effect {
+	return newEffect(LoadShader(effectVert, fragSrc))
+}
+
+func newEffect(program *webgl.Program) *Effect {
+	e := &Effect{
+		Enabled:  true,
+		params:   make(map[string][]float32),
+		textures: make(map[string]*Texture),
+	}
+	e.setShader(program)
+	return e
+}
+
+// SetSource recompiles the effect from fragSrc, keeping its parameters and
+// textures. If fragSrc does not compile, the effect keeps its current
+// shader and the error is returned.
+func (e *Effect) SetSource(fragSrc string) error {
+	program, err := CompileShader(effectVert, fragSrc)
+	if err != nil {
+		return err
+	}
+	DeleteShader(e.shader)
+	e.setShader(program)
+	return nil
+}
+
+func (e *Effect) setShader(program *webgl.Program) {
+	e.shader = program
+	e.inPosition = gl.GetAttribLocation(program, "in_Position")
+	e.uniforms = make(map[string]*webgl.UniformLocation)
+}
+
+// Delete frees the effect's shader. Textures given to SetTexture are left
+// alone.
+func (e *Effect) Delete() {
+	DeleteShader(e.shader)
+}
+
+// Set assigns one to four floats to the uniform uf_<name>.
+func (e *Effect) Set(name string, values ...float32) {
+	if len(values) < 1 || len(values) > 4 {
+		panic("Effect.Set takes between one and four values")
+	}
+	if _, ok := e.params[name]; !ok {
+		e.names = append(e.names, name)
+	}
+	e.params[name] = values
+}
+
+// Get returns the values last assigned to uf_<name>.
+func (e *Effect) Get(name string) []float32 {
+	return e.params[name]
+}
+
+// SetTexture binds texture to the sampler uniform uf_<name>.
+func (e *Effect) SetTexture(name string, texture *Texture) {
+	if _, ok := e.textures[name]; !ok {
+		e.samplers = append(e.samplers, name)
+	}
+	e.textures[name] = texture
+}
+
+func (e *Effect) uniform(name string) *webgl.UniformLocation {
+	loc, ok := e.uniforms[name]
+	if !ok {
+		loc = gl.GetUniformLocation(e.shader, name)
+		e.uniforms[name] = loc
+	}
+	return loc
+}
+
+// apply draws src through the effect into the currently bound framebuffer.
+func (e *Effect) apply(src *RenderTarget, quad *webgl.Buffer, width, height int) {
+	gl.UseProgram(e.shader)
+
+	for i, name := range e.samplers {
+		gl.ActiveTexture(gl.TEXTURE0 + i + 1)
+		gl.BindTexture(gl.TEXTURE_2D, e.textures[name].id)
+		gl.Uniform1i(e.uniform("uf_"+name), i+1)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, src.texture.id)
+	gl.Uniform1i(e.uniform("uf_Texture"), 0)
+
+	gl.Uniform2f(e.uniform("uf_Resolution"), float32(width), float32(height))
+	gl.Uniform1f(e.uniform("uf_Time"), Time.Time())
+
+	for _, name := range e.names {
+		v := e.params[name]
+		loc := e.uniform("uf_" + name)
+		switch len(v) {
+		case 1:
+			gl.Uniform1f(loc, v[0])
+		case 2:
+			gl.Uniform2f(loc, v[0], v[1])
+		case 3:
+			gl.Uniform3f(loc, v[0], v[1], v[2])
+		case 4:
+			gl.Uniform4f(loc, v[0], v[1], v[2], v[3])
+		}
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, quad)
+	gl.EnableVertexAttribArray(e.inPosition)
+	gl.VertexAttribPointer(e.inPosition, 2, gl.FLOAT, false, 8, 0)
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	gl.DisableVertexAttribArray(e.inPosition)
+}
+
+// PostProcessor renders a frame into an offscreen target and then runs it
+// through each enabled effect in order before drawing it to the screen.
+//
+//	post.Begin()
+//	batch.Begin()
+//	...
+//	batch.End()
+//	post.End()
+type PostProcessor struct {
+	Effects     []*Effect
+	scene       *RenderTarget
+	ping        *RenderTarget
+	pong        *RenderTarget
+	passthrough *Effect
+	quad        *webgl.Buffer
+	width       int
+	height      int
+}
+
+// NewPostProcessor creates a pipeline for frames of the given size in pixels.
+func NewPostProcessor(width, height int, effects ...*Effect) *PostProcessor {
+	p := &PostProcessor{
+		Effects:     effects,
+		passthrough: NewEffect(copyFrag),
+		quad:        gl.CreateBuffer(),
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, p.quad)
+	gl.BufferData(gl.ARRAY_BUFFER, []float32{
+		-1, -1, 1, -1, 1, 1,
+		-1, -1, 1, 1, -1, 1,
+	}, gl.STATIC_DRAW)
+
+	p.Resize(width, height)
+
+	return p
+}
+
+// Add appends effects to the end of the chain.
+func (p *PostProcessor) Add(effects ...*Effect) {
+	p.Effects = append(p.Effects, effects...)
+}
+
+// Resize recreates the offscreen targets to match a new frame size.
+func (p *PostProcessor) Resize(width, height int) {
+	p.width = width
+	p.height = height
+	p.deleteTargets()
+	options := DefaultTextureOptions
+	options.MagFilter = FilterLinear
+	p.scene = newPostTarget(width, height, options)
+	p.ping = newPostTarget(width, height, options)
+	p.pong = newPostTarget(width, height, options)
+}
+
+// newPostTarget creates a render target for a PostProcessor, which can do
+// without a stencil buffer.
+func newPostTarget(width, height int, options TextureOptions) *RenderTarget {
+	target, err := NewRenderTarget(width, height, options)
+	if err != nil && err != ErrNoStencil {
+		log.Fatal(err)
+	}
+	return target
+}
+
+// Delete frees the offscreen targets and buffers. The effects in the chain
+// are left alone, since they may be shared.
+func (p *PostProcessor) Delete() {
+	p.deleteTargets()
+	p.passthrough.Delete()
+	gl.DeleteBuffer(p.quad)
+}
+
+func (p *PostProcessor) deleteTargets() {
+	for _, t := range []*RenderTarget{p.scene, p.ping, p.pong} {
+		if t != nil {
+			t.Delete()
+		}
+	}
+}
+
+// Begin redirects drawing into the scene target and clears it to the
+// background color.
+func (p *PostProcessor) Begin() {
+	p.scene.Begin()
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+// End runs the scene through the effect chain into whatever was bound
+// before Begin.
+func (p *PostProcessor) End() {
+	p.scene.End()
+
+	var enabled []*Effect
+	for _, e := range p.Effects {
+		if e.Enabled {
+			enabled = append(enabled, e)
+		}
+	}
+	if len(enabled) == 0 {
+		enabled = append(enabled, p.passthrough)
+	}
+
+	gl.Disable(gl.BLEND)
+
+	src, dst := p.scene, p.ping
+	for i, e := range enabled {
+		if i == len(enabled)-1 {
+			e.apply(src, p.quad, p.width, p.height)
+			break
+		}
+		dst.Begin()
+		e.apply(src, p.quad, p.width, p.height)
+		dst.End()
+		if dst == p.ping {
+			src, dst = p.ping, p.pong
+		} else {
+			src, dst = p.pong, p.ping
+		}
+	}
+
+	gl.Enable(gl.BLEND)
+}
+
+// NewBlurEffect softens the image with a gaussian kernel whose taps are
+// Radius pixels apart.
+func NewBlurEffect(radius float32) *Effect {
+	e := NewEffect(blurFrag)
+	e.Set("Radius", radius)
+	return e
+}
+
+// NewBloomEffect adds a glow around anything brighter than Threshold, spread
+// over Radius pixels and scaled by Intensity.
+func NewBloomEffect(threshold, intensity, radius float32) *Effect {
+	e := NewEffect(bloomFrag)
+	e.Set("Threshold", threshold)
+	e.Set("Intensity", intensity)
+	e.Set("Radius", radius)
+	return e
+}
+
+// NewCRTEffect imitates a curved tube monitor. Curvature bends the image and
+// Scanlines sets how dark the gaps between lines are.
+func NewCRTEffect(curvature, scanlines float32) *Effect {
+	e := NewEffect(crtFrag)
+	e.Set("Curvature", curvature)
+	e.Set("Scanlines", scanlines)
+	return e
+}
+
+// NewColorGradeEffect remaps colors through lut, a 256x16 strip of sixteen
+// 16x16 blue slices, blended with the original by Intensity.
+func NewColorGradeEffect(lut *Texture, intensity float32) *Effect {
+	e := NewEffect(gradeFrag)
+	e.SetTexture("Lut", lut)
+	e.Set("Intensity", intensity)
+	return e
+}
+
+// NewVignetteEffect darkens the corners, starting at Radius from the center
+// and fading over Softness, scaled by Intensity.
+func NewVignetteEffect(radius, softness, intensity float32) *Effect {
+	e := NewEffect(vignetteFrag)
+	e.Set("Radius", radius)
+	e.Set("Softness", softness)
+	e.Set("Intensity", intensity)
+	return e
+}
+
+// NewChromaticAberrationEffect splits the red and blue channels Offset pixels
+// apart, increasing towards the edges.
+func NewChromaticAberrationEffect(offset float32) *Effect {
+	e := NewEffect(aberrationFrag)
+	e.Set("Offset", offset)
+	return e
+}
+
+var effectVert = `
+attribute vec2 in_Position;
+
+varying vec2 var_TexCoords;
+
+void main() {
+  var_TexCoords = in_Position * 0.5 + 0.5;
+  gl_Position = vec4(in_Position, 0.0, 1.0);
+}`
+
+var effectHeader = `
+#ifdef GL_ES
+precision mediump float;
+#endif
+
+varying vec2 var_TexCoords;
+
+uniform sampler2D uf_Texture;
+uniform vec2 uf_Resolution;
+uniform float uf_Time;
+`
+
+var copyFrag = effectHeader + `
+void main (void) {
+  gl_FragColor = texture2D(uf_Texture, var_TexCoords);
+}`
+
+var blurFrag = effectHeader + `
+uniform float uf_Radius;
+
+void main (void) {
+  vec2 d = uf_Radius / uf_Resolution;
+  vec4 sum = texture2D(uf_Texture, var_TexCoords) * 4.0;
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(-d.x, 0.0)) * 2.0;
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(d.x, 0.0)) * 2.0;
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(0.0, -d.y)) * 2.0;
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(0.0, d.y)) * 2.0;
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(-d.x, -d.y));
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(d.x, -d.y));
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(-d.x, d.y));
+  sum += texture2D(uf_Texture, var_TexCoords + vec2(d.x, d.y));
+  gl_FragColor = sum / 16.0;
+}`
+
+var bloomFrag = effectHeader + `
+uniform float uf_Threshold;
+uniform float uf_Intensity;
+uniform float uf_Radius;
+
+vec3 bright(vec2 offset) {
+  vec3 c = texture2D(uf_Texture, var_TexCoords + offset).rgb;
+  return max(c - uf_Threshold, 0.0);
+}
+
+void main (void) {
+  vec4 color = texture2D(uf_Texture, var_TexCoords);
+  vec2 d = uf_Radius / uf_Resolution;
+  vec3 glow = vec3(0.0);
+  for (int i = 1; i <= 2; i++) {
+    vec2 s = d * float(i) * 0.5;
+    glow += bright(vec2(-s.x, 0.0)) + bright(vec2(s.x, 0.0));
+    glow += bright(vec2(0.0, -s.y)) + bright(vec2(0.0, s.y));
+    glow += bright(vec2(-s.x, -s.y)) + bright(vec2(s.x, -s.y));
+    glow += bright(vec2(-s.x, s.y)) + bright(vec2(s.x, s.y));
+  }
+  gl_FragColor = vec4(color.rgb + glow / 16.0 * uf_Intensity, color.a);
+}`
+
+var crtFrag = effectHeader + `
+uniform float uf_Curvature;
+uniform float uf_Scanlines;
+
+void main (void) {
+  vec2 uv = var_TexCoords * 2.0 - 1.0;
+  uv *= 1.0 + uf_Curvature * dot(uv.yx, uv.yx);
+  uv = uv * 0.5 + 0.5;
+  if (uv.x < 0.0 || uv.x > 1.0 || uv.y < 0.0 || uv.y > 1.0) {
+    gl_FragColor = vec4(0.0, 0.0, 0.0, 1.0);
+    return;
+  }
+  vec4 color = texture2D(uf_Texture, uv);
+  float line = sin(uv.y * uf_Resolution.y * 3.14159265);
+  color.rgb *= 1.0 - uf_Scanlines * (0.5 - 0.5 * line);
+  gl_FragColor = color;
+}`
+
+var gradeFrag = effectHeader + `
+uniform sampler2D uf_Lut;
+uniform float uf_Intensity;
+
+void main (void) {
+  vec4 color = texture2D(uf_Texture, var_TexCoords);
+  float blue = clamp(color.b, 0.0, 1.0) * 15.0;
+  float slice0 = floor(blue);
+  float slice1 = min(slice0 + 1.0, 15.0);
+  vec2 rg = (clamp(color.rg, 0.0, 1.0) * 15.0 + 0.5) / vec2(256.0, 16.0);
+  vec3 c0 = texture2D(uf_Lut, rg + vec2(slice0 / 16.0, 0.0)).rgb;
+  vec3 c1 = texture2D(uf_Lut, rg + vec2(slice1 / 16.0, 0.0)).rgb;
+  vec3 graded = mix(c0, c1, blue - slice0);
+  gl_FragColor = vec4(mix(color.rgb, graded, uf_Intensity), color.a);
+}`
+
+var vignetteFrag = effectHeader + `
+uniform float uf_Radius;
+uniform float uf_Softness;
+uniform float uf_Intensity;
+
+void main (void) {
+  vec4 color = texture2D(uf_Texture, var_TexCoords);
+  float dist = distance(var_TexCoords, vec2(0.5));
+  float shade = smoothstep(uf_Radius, uf_Radius - uf_Softness, dist);
+  color.rgb *= mix(1.0, shade, uf_Intensity);
+  gl_FragColor = color;
+}`
+
+var aberrationFrag = effectHeader + `
+uniform float uf_Offset;
+
+void main (void) {
+  vec2 dir = var_TexCoords - 0.5;
+  vec2 d = dir * length(dir) * 2.0 * uf_Offset / uf_Resolution;
+  vec4 color = texture2D(uf_Texture, var_TexCoords);
+  color.r = texture2D(uf_Texture, var_TexCoords + d).r;
+  color.b = texture2D(uf_Texture, var_TexCoords - d).b;
+  gl_FragColor = color;
+}`
@@ -0,0 +1,181 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// watcher polls the files behind loaded resources for changes.
+type watcher struct {
+	interval time.Duration
+	last     time.Time
+	// mtimes holds the last seen modification time of each url. While a
+	// poll is running it belongs to the polling goroutine.
+	mtimes  map[string]time.Time
+	changes chan []Resource
+}
+
+// Watch turns on hot reloading for development. Every interval, the files
+// behind loaded resources are checked, and any that have changed are loaded
+// again. Textures, nine-patches and sounds are updated in place, so existing
+// references to them stay valid, and effects made by Effect are recompiled;
+// other resources are replaced and must be fetched again. Either way, if the
+// responder is a Reloader, its Reload is then called with the resource's
+// name. Only the desktop backend can watch files.
+func (l *Loader) Watch(interval time.Duration) error {
+	if !canWatch {
+		return errors.New("engi: hot reloading is not supported on this backend")
+	}
+	if l.watch == nil {
+		l.watch = &watcher{mtimes: make(map[string]time.Time)}
+	}
+	l.watch.interval = interval
+	return nil
+}
+
+// StopWatching turns off hot reloading.
+func (l *Loader) StopWatching() {
+	l.watch = nil
+}
+
+// poll starts checking the watched files if the interval has passed, and
+// reloads the resources found to have changed by the last check.
+func (l *Loader) poll() {
+	w := l.watch
+
+	if w.changes != nil {
+		select {
+		case changed := <-w.changes:
+			w.changes = nil
+			if len(changed) > 0 {
+				l.start(changed, true, nil)
+			}
+		default:
+		}
+		return
+	}
+
+	if time.Since(w.last) < w.interval {
+		return
+	}
+	w.last = time.Now()
+
+	resources := make([]Resource, 0, len(l.sources))
+	for _, r := range l.sources {
+		resources = append(resources, r)
+	}
+	w.changes = make(chan []Resource, 1)
+	go w.check(l.FS, resources)
+}
+
+func (w *watcher) check(fs *FS, resources []Resource) {
+	var changed []Resource
+	for _, r := range resources {
+		var mtime time.Time
+		var err error
+		if fs != nil {
+			mtime, err = fs.ModTime(r.url)
+		} else {
+			mtime, err = modTime(r.url)
+		}
+		if err != nil {
+			continue
+		}
+		last, seen := w.mtimes[r.url]
+		w.mtimes[r.url] = mtime
+		if seen && !mtime.Equal(last) {
+			changed = append(changed, r)
+		}
+	}
+	w.changes <- changed
+}
+
+// replace stores a reloaded resource, updating textures, nine-patches and
+// sounds in place.
+func (l *Loader) replace(r Resource, value interface{}) error {
+	if _, ok := loaders[r.kind]; ok {
+		return l.store(r, value)
+	}
+
+	switch old := l.assets[r.name].(type) {
+	case *Texture:
+		if r.kind == "image" {
+			old.upload(value.(Image), r.options)
+			return nil
+		}
+	case *NinePatch:
+		if r.kind == "ninepatch" {
+			img := value.(Image)
+			pixels, err := imagePixels(img)
+			if err != nil {
+				return err
+			}
+			left, top, right, bottom, err := ninePatchInsets(pixels)
+			if err != nil {
+				return err
+			}
+			texture := old.Region.texture
+			texture.upload(img, r.options)
+			w, h := pixels.Rect.Dx(), pixels.Rect.Dy()
+			patch := NewNinePatch(NewRegion(texture, 1, 1, w-2, h-2), left, top, right, bottom)
+			patch.TileEdges, patch.TileCenter = old.TileEdges, old.TileCenter
+			*old = *patch
+			return nil
+		}
+	case *Sound:
+		if r.kind == "sound" {
+			player, err := newPlayer(value)
+			if err != nil {
+				return err
+			}
+			if old.Player != nil {
+				old.Close()
+			}
+			old.Player = player
+			return nil
+		}
+	}
+
+	if err := l.store(r, value); err != nil {
+		return err
+	}
+	if r.kind == "shader" {
+		return l.recompile(r.name)
+	}
+	return nil
+}
+
+// Effect compiles the shader resource called name into a new effect. While
+// watching, the effect is recompiled whenever the file changes; if the new
+// source fails to compile, the effect keeps its old shader and the error is
+// logged. Effects are tracked until the resource is unloaded.
+func (l *Loader) Effect(name string) (*Effect, error) {
+	r, ok := l.sources[name]
+	if !ok || r.kind != "shader" {
+		return nil, fmt.Errorf("engi: no shader resource called %q", name)
+	}
+	program, err := CompileShader(effectVert, l.texts[name])
+	if err != nil {
+		return nil, &ResourceError{name, r.url, err}
+	}
+	e := newEffect(program)
+	l.effects[name] = append(l.effects[name], e)
+	return e, nil
+}
+
+// recompile rebuilds the effects made from the shader resource called name,
+// returning the first error.
+func (l *Loader) recompile(name string) error {
+	var first error
+	for _, e := range l.effects[name] {
+		if err := e.SetSource(l.texts[name]); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
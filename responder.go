@@ -33,3 +33,16 @@ func (g *Game) Key(key Key, modifier Modifier, action Action) {
 	}
 }
 func (g *Game) Type(char rune) {}
+
+// LoadingResponder is a Responder that draws while the resources queued by
+// Preload load. Loading is called each frame in place of Update and Render
+// until they have all finished.
+type LoadingResponder interface {
+	Loading(progress Progress)
+}
+
+// Reloader is a Responder told when Files reloads a resource that changed
+// on disk, so it can fetch the resource again.
+type Reloader interface {
+	Reload(name string)
+}
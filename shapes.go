@@ -0,0 +1,411 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import "math"
+
+// whiteTexture is the solid texture shapes are drawn with, so they batch
+// together with sprites.
+var whiteTexture *Texture
+
+func white() *Texture {
+	if whiteTexture == nil {
+		whiteTexture = NewTexture(newWhiteImage(4, 4), PixelTextureOptions)
+	}
+	return whiteTexture
+}
+
+var triangleIndices = []uint16{0, 1, 2}
+
+// triangle appends a solid triangle.
+func (b *Batch) triangle(x1, y1, x2, y2, x3, y3, tint float32) {
+	v := [15]float32{
+		x1, y1, 0.5, 0.5, tint,
+		x2, y2, 0.5, 0.5, tint,
+		x3, y3, 0.5, 0.5, tint,
+	}
+	b.mesh(white().id, v[:], triangleIndices)
+}
+
+// solidQuad appends a solid quad with corners in drawing order.
+func (b *Batch) solidQuad(x1, y1, x2, y2, x3, y3, x4, y4, tint float32) {
+	b.quad(white().id, &[20]float32{
+		x1, y1, 0.5, 0.5, tint,
+		x2, y2, 0.5, 0.5, tint,
+		x3, y3, 0.5, 0.5, tint,
+		x4, y4, 0.5, 0.5, tint,
+	})
+}
+
+// FillRect draws a solid rectangle.
+func (b *Batch) FillRect(x, y, w, h float32, color uint32, transparency float32) {
+	b.solidQuad(x, y, x+w, y, x+w, y+h, x, y+h, packColor(color, transparency))
+}
+
+// StrokeRect draws the outline of a rectangle, thickness pixels wide inside
+// its bounds.
+func (b *Batch) StrokeRect(x, y, w, h, thickness float32, color uint32, transparency float32) {
+	tint := packColor(color, transparency)
+	t := thickness
+	if t*2 >= w || t*2 >= h {
+		b.solidQuad(x, y, x+w, y, x+w, y+h, x, y+h, tint)
+		return
+	}
+	b.solidQuad(x, y, x+w, y, x+w, y+t, x, y+t, tint)
+	b.solidQuad(x, y+h-t, x+w, y+h-t, x+w, y+h, x, y+h, tint)
+	b.solidQuad(x, y+t, x+t, y+t, x+t, y+h-t, x, y+h-t, tint)
+	b.solidQuad(x+w-t, y+t, x+w, y+t, x+w, y+h-t, x+w-t, y+h-t, tint)
+}
+
+// DrawLine draws a straight line thickness pixels wide.
+func (b *Batch) DrawLine(x1, y1, x2, y2, thickness float32, color uint32, transparency float32) {
+	dx, dy := x2-x1, y2-y1
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	if length == 0 {
+		return
+	}
+	nx := -dy / length * thickness / 2
+	ny := dx / length * thickness / 2
+	b.solidQuad(x1+nx, y1+ny, x2+nx, y2+ny, x2-nx, y2-ny, x1-nx, y1-ny, packColor(color, transparency))
+}
+
+// FillCircle draws a solid circle centered on x, y.
+func (b *Batch) FillCircle(x, y, radius float32, color uint32, transparency float32) {
+	b.FillEllipse(x, y, radius, radius, color, transparency)
+}
+
+// StrokeCircle draws the outline of a circle centered on x, y.
+func (b *Batch) StrokeCircle(x, y, radius, thickness float32, color uint32, transparency float32) {
+	b.StrokeEllipse(x, y, radius, radius, thickness, color, transparency)
+}
+
+// FillEllipse draws a solid ellipse centered on x, y.
+func (b *Batch) FillEllipse(x, y, radiusX, radiusY float32, color uint32, transparency float32) {
+	points := arcPoints(x, y, radiusX, radiusY, 0, 360)
+	b.fillFan(x, y, points[:len(points)-1], true, packColor(color, transparency))
+}
+
+// StrokeEllipse draws the outline of an ellipse centered on x, y.
+func (b *Batch) StrokeEllipse(x, y, radiusX, radiusY, thickness float32, color uint32, transparency float32) {
+	points := arcPoints(x, y, radiusX, radiusY, 0, 360)
+	b.strokePath(points[:len(points)-1], true, thickness, packColor(color, transparency))
+}
+
+// FillArc draws a solid pie slice centered on x, y, from start to end
+// degrees clockwise.
+func (b *Batch) FillArc(x, y, radius, start, end float32, color uint32, transparency float32) {
+	b.fillFan(x, y, arcPoints(x, y, radius, radius, start, end), false, packColor(color, transparency))
+}
+
+// StrokeArc draws the curved edge of a circle centered on x, y, from start
+// to end degrees clockwise.
+func (b *Batch) StrokeArc(x, y, radius, start, end, thickness float32, color uint32, transparency float32) {
+	b.strokePath(arcPoints(x, y, radius, radius, start, end), false, thickness, packColor(color, transparency))
+}
+
+// FillRoundedRect draws a solid rectangle whose corners are rounded by radius.
+func (b *Batch) FillRoundedRect(x, y, w, h, radius float32, color uint32, transparency float32) {
+	points := roundedRectPoints(x, y, w, h, radius)
+	b.fillFan(x+w/2, y+h/2, points, true, packColor(color, transparency))
+}
+
+// StrokeRoundedRect draws the outline of a rectangle whose corners are
+// rounded by radius.
+func (b *Batch) StrokeRoundedRect(x, y, w, h, radius, thickness float32, color uint32, transparency float32) {
+	b.strokePath(roundedRectPoints(x, y, w, h, radius), true, thickness, packColor(color, transparency))
+}
+
+// FillPolygon draws a solid simple polygon, which may be concave.
+func (b *Batch) FillPolygon(points []Point, color uint32, transparency float32) {
+	tint := packColor(color, transparency)
+	indices := triangulate(points)
+	for i := 0; i+2 < len(indices); i += 3 {
+		p1, p2, p3 := points[indices[i]], points[indices[i+1]], points[indices[i+2]]
+		b.triangle(p1.X, p1.Y, p2.X, p2.Y, p3.X, p3.Y, tint)
+	}
+}
+
+// StrokePolygon draws the closed outline of a polygon.
+func (b *Batch) StrokePolygon(points []Point, thickness float32, color uint32, transparency float32) {
+	b.strokePath(points, true, thickness, packColor(color, transparency))
+}
+
+// StrokePolyline draws connected line segments through points.
+func (b *Batch) StrokePolyline(points []Point, thickness float32, color uint32, transparency float32) {
+	b.strokePath(points, false, thickness, packColor(color, transparency))
+}
+
+// FillRectColor is FillRect with a Color, which may be translucent.
+func (b *Batch) FillRectColor(x, y, w, h float32, c Color) {
+	b.FillRect(x, y, w, h, c.Hex(), c.A)
+}
+
+// StrokeRectColor is StrokeRect with a Color, which may be translucent.
+func (b *Batch) StrokeRectColor(x, y, w, h, thickness float32, c Color) {
+	b.StrokeRect(x, y, w, h, thickness, c.Hex(), c.A)
+}
+
+// DrawLineColor is DrawLine with a Color, which may be translucent.
+func (b *Batch) DrawLineColor(x1, y1, x2, y2, thickness float32, c Color) {
+	b.DrawLine(x1, y1, x2, y2, thickness, c.Hex(), c.A)
+}
+
+// FillCircleColor is FillCircle with a Color, which may be translucent.
+func (b *Batch) FillCircleColor(x, y, radius float32, c Color) {
+	b.FillCircle(x, y, radius, c.Hex(), c.A)
+}
+
+// StrokeCircleColor is StrokeCircle with a Color, which may be translucent.
+func (b *Batch) StrokeCircleColor(x, y, radius, thickness float32, c Color) {
+	b.StrokeCircle(x, y, radius, thickness, c.Hex(), c.A)
+}
+
+// FillEllipseColor is FillEllipse with a Color, which may be translucent.
+func (b *Batch) FillEllipseColor(x, y, radiusX, radiusY float32, c Color) {
+	b.FillEllipse(x, y, radiusX, radiusY, c.Hex(), c.A)
+}
+
+// StrokeEllipseColor is StrokeEllipse with a Color, which may be translucent.
+func (b *Batch) StrokeEllipseColor(x, y, radiusX, radiusY, thickness float32, c Color) {
+	b.StrokeEllipse(x, y, radiusX, radiusY, thickness, c.Hex(), c.A)
+}
+
+// FillArcColor is FillArc with a Color, which may be translucent.
+func (b *Batch) FillArcColor(x, y, radius, start, end float32, c Color) {
+	b.FillArc(x, y, radius, start, end, c.Hex(), c.A)
+}
+
+// StrokeArcColor is StrokeArc with a Color, which may be translucent.
+func (b *Batch) StrokeArcColor(x, y, radius, start, end, thickness float32, c Color) {
+	b.StrokeArc(x, y, radius, start, end, thickness, c.Hex(), c.A)
+}
+
+// FillRoundedRectColor is FillRoundedRect with a Color, which may be translucent.
+func (b *Batch) FillRoundedRectColor(x, y, w, h, radius float32, c Color) {
+	b.FillRoundedRect(x, y, w, h, radius, c.Hex(), c.A)
+}
+
+// StrokeRoundedRectColor is StrokeRoundedRect with a Color, which may be translucent.
+func (b *Batch) StrokeRoundedRectColor(x, y, w, h, radius, thickness float32, c Color) {
+	b.StrokeRoundedRect(x, y, w, h, radius, thickness, c.Hex(), c.A)
+}
+
+// FillPolygonColor is FillPolygon with a Color, which may be translucent.
+func (b *Batch) FillPolygonColor(points []Point, c Color) {
+	b.FillPolygon(points, c.Hex(), c.A)
+}
+
+// StrokePolygonColor is StrokePolygon with a Color, which may be translucent.
+func (b *Batch) StrokePolygonColor(points []Point, thickness float32, c Color) {
+	b.StrokePolygon(points, thickness, c.Hex(), c.A)
+}
+
+// StrokePolylineColor is StrokePolyline with a Color, which may be translucent.
+func (b *Batch) StrokePolylineColor(points []Point, thickness float32, c Color) {
+	b.StrokePolyline(points, thickness, c.Hex(), c.A)
+}
+
+// fillFan draws triangles from the center cx, cy to each edge of points.
+func (b *Batch) fillFan(cx, cy float32, points []Point, closed bool, tint float32) {
+	for i := 0; i+1 < len(points); i++ {
+		b.triangle(cx, cy, points[i].X, points[i].Y, points[i+1].X, points[i+1].Y, tint)
+	}
+	if closed && len(points) > 2 {
+		last := points[len(points)-1]
+		b.triangle(cx, cy, last.X, last.Y, points[0].X, points[0].Y, tint)
+	}
+}
+
+// strokePath draws a line of the given thickness along points, with mitered
+// joins between segments.
+func (b *Batch) strokePath(points []Point, closed bool, thickness, tint float32) {
+	n := len(points)
+	if n < 2 {
+		return
+	}
+
+	outer := make([]Point, n)
+	inner := make([]Point, n)
+	half := thickness / 2
+
+	for i := range points {
+		p := points[i]
+		var prev, next Point
+		hasPrev := i > 0 || closed
+		hasNext := i < n-1 || closed
+		if hasPrev {
+			prev = points[(i+n-1)%n]
+		}
+		if hasNext {
+			next = points[(i+1)%n]
+		}
+
+		var nx, ny float32
+		switch {
+		case hasPrev && hasNext:
+			ax, ay := normal(prev, p)
+			bx, by := normal(p, next)
+			mx, my := ax+bx, ay+by
+			ml := float32(math.Hypot(float64(mx), float64(my)))
+			if ml < 1e-4 {
+				nx, ny = ax*half, ay*half
+				break
+			}
+			mx, my = mx/ml, my/ml
+			scale := half / (mx*ax + my*ay)
+			if scale > thickness*2 {
+				scale = thickness * 2
+			}
+			nx, ny = mx*scale, my*scale
+		case hasNext:
+			nx, ny = normal(p, next)
+			nx, ny = nx*half, ny*half
+		default:
+			nx, ny = normal(prev, p)
+			nx, ny = nx*half, ny*half
+		}
+
+		outer[i] = Point{p.X + nx, p.Y + ny}
+		inner[i] = Point{p.X - nx, p.Y - ny}
+	}
+
+	segments := n - 1
+	if closed {
+		segments = n
+	}
+	for i := 0; i < segments; i++ {
+		j := (i + 1) % n
+		b.solidQuad(outer[i].X, outer[i].Y, outer[j].X, outer[j].Y, inner[j].X, inner[j].Y, inner[i].X, inner[i].Y, tint)
+	}
+}
+
+// normal returns the unit vector perpendicular to the segment from a to b.
+func normal(a, b Point) (float32, float32) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	if length == 0 {
+		return 0, 0
+	}
+	return -dy / length, dx / length
+}
+
+// segmentsFor picks how many segments approximate a curve of the given
+// radius swept through degrees.
+func segmentsFor(radius, degrees float32) int {
+	n := int(math.Ceil(float64(radius) * math.Abs(float64(degrees)) * math.Pi / 180 / 4))
+	if n < 4 {
+		n = 4
+	}
+	if n > 256 {
+		n = 256
+	}
+	return n
+}
+
+// arcPoints returns points along an ellipse from start to end degrees,
+// including both ends.
+func arcPoints(x, y, radiusX, radiusY, start, end float32) []Point {
+	r := radiusX
+	if radiusY > r {
+		r = radiusY
+	}
+	n := segmentsFor(r, end-start)
+	points := make([]Point, n+1)
+	for i := 0; i <= n; i++ {
+		a := float64(start+(end-start)*float32(i)/float32(n)) * math.Pi / 180
+		points[i] = Point{x + radiusX*float32(math.Cos(a)), y + radiusY*float32(math.Sin(a))}
+	}
+	return points
+}
+
+// roundedRectPoints returns the outline of a rounded rectangle, clockwise
+// from the end of the top left corner.
+func roundedRectPoints(x, y, w, h, radius float32) []Point {
+	if radius > w/2 {
+		radius = w / 2
+	}
+	if radius > h/2 {
+		radius = h / 2
+	}
+	if radius <= 0 {
+		return []Point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}
+	}
+	var points []Point
+	points = append(points, arcPoints(x+w-radius, y+radius, radius, radius, 270, 360)...)
+	points = append(points, arcPoints(x+w-radius, y+h-radius, radius, radius, 0, 90)...)
+	points = append(points, arcPoints(x+radius, y+h-radius, radius, radius, 90, 180)...)
+	points = append(points, arcPoints(x+radius, y+radius, radius, radius, 180, 270)...)
+	return points
+}
+
+// triangulate splits a simple polygon into triangles by ear clipping and
+// returns them as indices into points.
+func triangulate(points []Point) []int {
+	n := len(points)
+	if n < 3 {
+		return nil
+	}
+
+	var area float32
+	for i := range points {
+		j := (i + 1) % n
+		area += points[i].X*points[j].Y - points[j].X*points[i].Y
+	}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		if area > 0 {
+			remaining[i] = i
+		} else {
+			remaining[i] = n - 1 - i
+		}
+	}
+
+	indices := make([]int, 0, (n-2)*3)
+	for guard := 0; len(remaining) > 3 && guard < n*n; guard++ {
+		m := len(remaining)
+		clipped := false
+		for i := 0; i < m; i++ {
+			a, b, c := remaining[(i+m-1)%m], remaining[i], remaining[(i+1)%m]
+			if !isEar(points, remaining, a, b, c) {
+				continue
+			}
+			indices = append(indices, a, b, c)
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			clipped = true
+			break
+		}
+		if !clipped {
+			break
+		}
+	}
+
+	for i := 1; i+1 < len(remaining); i++ {
+		indices = append(indices, remaining[0], remaining[i], remaining[i+1])
+	}
+
+	return indices
+}
+
+func isEar(points []Point, remaining []int, a, b, c int) bool {
+	pa, pb, pc := points[a], points[b], points[c]
+	if cross(pa, pb, pc) <= 0 {
+		return false
+	}
+	for _, i := range remaining {
+		if i == a || i == b || i == c {
+			continue
+		}
+		p := points[i]
+		if cross(pa, pb, p) >= 0 && cross(pb, pc, p) >= 0 && cross(pc, pa, p) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func cross(a, b, c Point) float32 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
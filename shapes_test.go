@@ -0,0 +1,61 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"math"
+	"testing"
+)
+
+func polygonArea(points []Point) float32 {
+	var area float32
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += points[i].X*points[j].Y - points[j].X*points[i].Y
+	}
+	return float32(math.Abs(float64(area))) / 2
+}
+
+func TestTriangulate(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+	}{
+		{"triangle", []Point{{0, 0}, {4, 0}, {0, 3}}},
+		{"square", []Point{{0, 0}, {2, 0}, {2, 2}, {0, 2}}},
+		{"square clockwise", []Point{{0, 0}, {0, 2}, {2, 2}, {2, 0}}},
+		{"l shape", []Point{{0, 0}, {3, 0}, {3, 1}, {1, 1}, {1, 3}, {0, 3}}},
+		{"arrow", []Point{{0, 0}, {2, 1}, {4, 0}, {2, 4}}},
+		{"star", []Point{{0, 3}, {2, 2}, {3, 0}, {4, 2}, {6, 3}, {4, 4}, {3, 6}, {2, 4}}},
+	}
+
+	for _, tt := range tests {
+		indices := triangulate(tt.points)
+		if want := (len(tt.points) - 2) * 3; len(indices) != want {
+			t.Errorf("%s: got %d indices, want %d", tt.name, len(indices), want)
+			continue
+		}
+
+		var area float32
+		for i := 0; i < len(indices); i += 3 {
+			a, b, c := tt.points[indices[i]], tt.points[indices[i+1]], tt.points[indices[i+2]]
+			if cross(a, b, c) < 0 {
+				t.Errorf("%s: triangle %d is wound clockwise", tt.name, i/3)
+			}
+			area += polygonArea([]Point{a, b, c})
+		}
+		if want := polygonArea(tt.points); math.Abs(float64(area-want)) > 1e-4 {
+			t.Errorf("%s: triangles cover %v, want %v", tt.name, area, want)
+		}
+	}
+}
+
+func TestTriangulateTooFewPoints(t *testing.T) {
+	for _, points := range [][]Point{nil, {{0, 0}}, {{0, 0}, {1, 1}}} {
+		if indices := triangulate(points); indices != nil {
+			t.Errorf("triangulate(%v) = %v, want nil", points, indices)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"log"
+	"sort"
+
+	"github.com/ajhager/webgl"
+)
+
+// SetSorted switches the batch between drawing immediately, in call order,
+// and deferring draws until End, where they are drawn sorted by layer, then
+// depth, then texture. Draws with equal keys keep their call order within
+// each texture.
+func (b *Batch) SetSorted(sorted bool) {
+	if b.drawing {
+		log.Fatal("Batch.SetSorted() must be called outside of Begin/End")
+	}
+	b.sorted = sorted
+}
+
+// SetDepth sets the sort keys of subsequent draws in sorted mode. Lower
+// layers are drawn first, and within a layer lower depths are drawn first,
+// so passing a sprite's Y position as its depth draws a top-down world in
+// painter's order. Begin resets both to zero.
+func (b *Batch) SetDepth(layer int, depth float32) {
+	b.layer = layer
+	b.depth = depth
+}
+
+type deferredDraw struct {
+	layer       int
+	depth       float32
+	texture     int
+	vertexStart int
+	vertexEnd   int
+	indexStart  int
+	indexEnd    int
+}
+
+// deferredDraws holds the meshes drawn in sorted mode, with their vertices
+// and indices packed end to end.
+type deferredDraws struct {
+	draws    []deferredDraw
+	vertices []float32
+	indices  []uint16
+	textures []*webgl.Texture
+	ids      map[*webgl.Texture]int
+}
+
+func (d *deferredDraws) add(texture *webgl.Texture, layer int, depth float32, vertices []float32, indices []uint16) {
+	if d.ids == nil {
+		d.ids = make(map[*webgl.Texture]int)
+	}
+	id, ok := d.ids[texture]
+	if !ok {
+		id = len(d.textures)
+		d.ids[texture] = id
+		d.textures = append(d.textures, texture)
+	}
+	draw := deferredDraw{
+		layer:       layer,
+		depth:       depth,
+		texture:     id,
+		vertexStart: len(d.vertices),
+		indexStart:  len(d.indices),
+	}
+	d.vertices = append(d.vertices, vertices...)
+	d.indices = append(d.indices, indices...)
+	draw.vertexEnd = len(d.vertices)
+	draw.indexEnd = len(d.indices)
+	d.draws = append(d.draws, draw)
+}
+
+func (d *deferredDraws) reset() {
+	d.draws = d.draws[:0]
+	d.vertices = d.vertices[:0]
+	d.indices = d.indices[:0]
+	d.textures = d.textures[:0]
+	for t := range d.ids {
+		delete(d.ids, t)
+	}
+}
+
+func (d *deferredDraws) Len() int {
+	return len(d.draws)
+}
+
+func (d *deferredDraws) Swap(i, j int) {
+	d.draws[i], d.draws[j] = d.draws[j], d.draws[i]
+}
+
+func (d *deferredDraws) Less(i, j int) bool {
+	a, b := &d.draws[i], &d.draws[j]
+	if a.layer != b.layer {
+		return a.layer < b.layer
+	}
+	if a.depth != b.depth {
+		return a.depth < b.depth
+	}
+	return a.texture < b.texture
+}
+
+// drawDeferred sorts the draws held in sorted mode and emits them.
+func (b *Batch) drawDeferred() {
+	d := &b.deferred
+	sort.Stable(d)
+	for i := range d.draws {
+		draw := &d.draws[i]
+		b.emit(d.textures[draw.texture],
+			d.vertices[draw.vertexStart:draw.vertexEnd],
+			d.indices[draw.indexStart:draw.indexEnd])
+	}
+	d.reset()
+}
@@ -0,0 +1,123 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"engo.io/audio"
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/mewkiz/flac"
+)
+
+// decodeSound decodes the contents of the sound file at url. Formats are told
+// apart by their signatures, with the extension deciding between FLAC and MP3
+// files that start with an ID3 tag.
+func decodeSound(url string, data []byte) (*pcm, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("RIFF")):
+		return decodeWAV(data)
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return decodeOGG(data)
+	case bytes.HasPrefix(data, []byte("fLaC")),
+		bytes.HasPrefix(data, []byte("ID3")) && extension(url) == "flac":
+		return decodeFLAC(data)
+	case bytes.HasPrefix(data, []byte("ID3")),
+		len(data) > 1 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return decodeMP3(data)
+	}
+	return nil, errors.New("unknown sound format, use WAV, OGG, MP3 or FLAC")
+}
+
+// decodeOGG decodes an Ogg Vorbis file to 16 bit samples.
+func decodeOGG(data []byte) (*pcm, error) {
+	samples, info, err := oggvorbis.ReadAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ogg: %v", err)
+	}
+	format, err := pcm16(info.Channels)
+	if err != nil {
+		return nil, fmt.Errorf("ogg: %v", err)
+	}
+	out := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		if s > 1 {
+			s = 1
+		} else if s < -1 {
+			s = -1
+		}
+		v := int16(s * math.MaxInt16)
+		out[2*i], out[2*i+1] = byte(v), byte(v>>8)
+	}
+	return &pcm{format, int64(info.SampleRate), out}, nil
+}
+
+// decodeMP3 decodes an MP3 file, which always comes out as 16 bit stereo.
+func decodeMP3(data []byte) (*pcm, error) {
+	d, err := mp3.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("mp3: %v", err)
+	}
+	out, err := ioutil.ReadAll(d)
+	if err != nil {
+		return nil, fmt.Errorf("mp3: %v", err)
+	}
+	return &pcm{audio.Stereo16, int64(d.SampleRate()), out}, nil
+}
+
+// decodeFLAC decodes a FLAC file, scaling its samples to 16 bits.
+func decodeFLAC(data []byte) (*pcm, error) {
+	stream, err := flac.New(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("flac: %v", err)
+	}
+	defer stream.Close()
+
+	channels := int(stream.Info.NChannels)
+	format, err := pcm16(channels)
+	if err != nil {
+		return nil, fmt.Errorf("flac: %v", err)
+	}
+	bits := uint(stream.Info.BitsPerSample)
+	var out []byte
+	for {
+		f, err := stream.ParseNext()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("flac: %v", err)
+		}
+		for i := range f.Subframes[0].Samples {
+			for _, sub := range f.Subframes {
+				s := sub.Samples[i]
+				if bits > 16 {
+					s >>= bits - 16
+				} else {
+					s <<= 16 - bits
+				}
+				out = append(out, byte(s), byte(s>>8))
+			}
+		}
+	}
+	return &pcm{format, int64(stream.Info.SampleRate), out}, nil
+}
+
+// pcm16 returns the 16 bit format for a number of channels.
+func pcm16(channels int) (audio.Format, error) {
+	switch channels {
+	case 1:
+		return audio.Mono16, nil
+	case 2:
+		return audio.Stereo16, nil
+	}
+	return 0, fmt.Errorf("unsupported channel count %d, only mono and stereo are supported", channels)
+}
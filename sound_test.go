@@ -0,0 +1,112 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"testing"
+
+	"engo.io/audio"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// flacFile encodes one frame of verbatim samples per channel.
+func flacFile(t *testing.T, bits uint8, channels ...[]int32) []byte {
+	n := len(channels[0])
+	var buf bytes.Buffer
+	// Streams declare at least 16 samples a block; only the last may be shorter.
+	enc, err := flac.NewEncoder(&buf, &meta.StreamInfo{
+		BlockSizeMin:  16,
+		BlockSizeMax:  16,
+		SampleRate:    32000,
+		NChannels:     uint8(len(channels)),
+		BitsPerSample: bits,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout := map[int]frame.Channels{1: frame.ChannelsMono, 2: frame.ChannelsLR, 3: frame.ChannelsLRC}
+	f := &frame.Frame{Header: frame.Header{
+		HasFixedBlockSize: true,
+		BlockSize:         uint16(n),
+		SampleRate:        32000,
+		Channels:          layout[len(channels)],
+		BitsPerSample:     bits,
+	}}
+	for _, samples := range channels {
+		f.Subframes = append(f.Subframes, &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+			Samples:   samples,
+			NSamples:  n,
+		})
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadFLAC(t *testing.T) {
+	fs := NewFS()
+	fs.Mount("", MapSource{
+		"music/theme.flac": flacFile(t, 16, []int32{1, -2, 0x1234}, []int32{-1, 2, -0x1234}),
+		"music/quiet.flac": flacFile(t, 8, []int32{1, -1}),
+		"music/loud.flac":  flacFile(t, 24, []int32{0x123456, -0x100}),
+	})
+
+	tests := []struct {
+		url    string
+		format audio.Format
+		pcm    []byte
+	}{
+		{"music/theme.flac", audio.Stereo16, []byte{1, 0, 0xFF, 0xFF, 0xFE, 0xFF, 2, 0, 0x34, 0x12, 0xCC, 0xED}},
+		{"music/quiet.flac", audio.Mono16, []byte{0, 1, 0, 0xFF}},
+		{"music/loud.flac", audio.Mono16, []byte{0x34, 0x12, 0xFF, 0xFF}},
+	}
+	for _, test := range tests {
+		r := Resource{name: test.url, url: test.url, kind: extensions["flac"]}
+		value, _, err := read(fs, r)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		sound := value.(*pcm)
+		if sound.format != test.format || sound.rate != 32000 || !bytes.Equal(sound.data, test.pcm) {
+			t.Errorf("%s: got %v at %d Hz %v, want %v at 32000 Hz %v", test.url, sound.format, sound.rate, sound.data, test.format, test.pcm)
+		}
+	}
+}
+
+func TestDecodeSoundErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		data []byte
+		err  string
+	}{
+		{"unknown", "a.snd", []byte("junk"), "unknown sound format, use WAV, OGG, MP3 or FLAC"},
+		{"empty", "a.wav", nil, "unknown sound format, use WAV, OGG, MP3 or FLAC"},
+		{"surround flac", "a.flac", flacFile(t, 16, []int32{1}, []int32{2}, []int32{3}), "flac: unsupported channel count 3, only mono and stereo are supported"},
+	}
+	for _, test := range tests {
+		_, err := decodeSound(test.url, test.data)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	// The decoders' own messages vary, but each names its format.
+	for _, data := range [][]byte{[]byte("OggS\x00junk"), []byte("fLaCjunk"), []byte("ID3junk")} {
+		_, err := decodeSound("a", data)
+		if err == nil {
+			t.Errorf("%q: decoded", data)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import "fmt"
+
+type flushReason int
+
+const (
+	flushEnd flushReason = iota
+	flushTexture
+	flushFull
+	flushState
+)
+
+// BatchStats counts the work done by batches. Every flush is one draw call,
+// and is attributed to the reason it happened.
+type BatchStats struct {
+	DrawCalls int
+	// Sprites counts the quads, shapes and meshes drawn.
+	Sprites  int
+	Vertices int
+
+	// TextureFlushes happen when a new texture is drawn while every texture
+	// unit is in use.
+	TextureFlushes int
+	// FullFlushes happen when the vertex buffer runs out of space.
+	FullFlushes int
+	// StateFlushes happen when the projection or other state changes while
+	// drawing.
+	StateFlushes int
+	// EndFlushes draw whatever remains when End is called.
+	EndFlushes int
+}
+
+func (s *BatchStats) count(reason flushReason, sprites, vertices int) {
+	s.DrawCalls++
+	s.Sprites += sprites
+	s.Vertices += vertices
+	switch reason {
+	case flushEnd:
+		s.EndFlushes++
+	case flushTexture:
+		s.TextureFlushes++
+	case flushFull:
+		s.FullFlushes++
+	case flushState:
+		s.StateFlushes++
+	}
+}
+
+func (s BatchStats) String() string {
+	return fmt.Sprintf("%d draws, %d sprites, %d vertices (%d texture, %d full, %d state, %d end)",
+		s.DrawCalls, s.Sprites, s.Vertices,
+		s.TextureFlushes, s.FullFlushes, s.StateFlushes, s.EndFlushes)
+}
+
+var frameStats, lastFrameStats BatchStats
+
+// endFrame is called by the backends once a frame has been rendered.
+func endFrame() {
+	lastFrameStats = frameStats
+	frameStats = BatchStats{}
+}
+
+// FrameStats returns the totals of every batch during the last frame.
+func FrameStats() BatchStats {
+	return lastFrameStats
+}
+
+// Stats returns the counts of the batch since its last Begin.
+func (b *Batch) Stats() BatchStats {
+	return b.stats
+}
+
+// DrawStats prints the last frame's stats and the frame rate with font, one
+// per line, starting at x, y.
+func DrawStats(batch *Batch, font *Font, x, y float32, color uint32) {
+	s := FrameStats()
+	lines := []string{
+		fmt.Sprintf("FPS %d", int(Time.Fps())),
+		fmt.Sprintf("DRAWS %d", s.DrawCalls),
+		fmt.Sprintf("SPRITES %d", s.Sprites),
+		fmt.Sprintf("VERTICES %d", s.Vertices),
+		fmt.Sprintf("FLUSH TEX %d FULL %d STATE %d", s.TextureFlushes, s.FullFlushes, s.StateFlushes),
+	}
+	height := font.lineHeight()
+	for i, line := range lines {
+		font.Print(batch, line, x, y+float32(i)*height, color)
+	}
+}
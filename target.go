@@ -0,0 +1,171 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"errors"
+
+	"github.com/ajhager/webgl"
+)
+
+var (
+	targets        []*RenderTarget
+	viewportWidth  int
+	viewportHeight int
+)
+
+// setViewport records the size of the default framebuffer, applying it
+// unless a render target is currently bound.
+func setViewport(width, height int) {
+	viewportWidth = width
+	viewportHeight = height
+	if len(targets) == 0 {
+		gl.Viewport(0, 0, width, height)
+	}
+}
+
+// viewport returns the size in pixels of the framebuffer being drawn to.
+func viewport() (int, int) {
+	if n := len(targets); n > 0 {
+		return targets[n-1].texture.width, targets[n-1].texture.height
+	}
+	return viewportWidth, viewportHeight
+}
+
+// RenderTarget is an offscreen framebuffer with a color texture. It can be
+// drawn to by a Batch and then drawn itself, since it is a Drawable.
+type RenderTarget struct {
+	fbo     *webgl.FrameBuffer
+	stencil *webgl.RenderBuffer
+	texture *Texture
+}
+
+// ErrNoStencil is returned with a working render target when the platform
+// cannot give it a stencil buffer. Drawing to it works, but masks do not.
+var ErrNoStencil = errors.New("engi: render target has no stencil buffer")
+
+// NewRenderTarget creates a render target of the given size in pixels. The
+// first of opts, if any, sets how its color texture is sampled. It has a
+// packed depth and stencil buffer for masks where the platform supports one;
+// where it does not, the target is still returned, along with ErrNoStencil.
+func NewRenderTarget(width, height int, opts ...TextureOptions) (*RenderTarget, error) {
+	texture := NewTexture(newBlankImage(width, height), opts...)
+
+	stencil := gl.CreateRenderbuffer()
+	gl.BindRenderbuffer(gl.RENDERBUFFER, stencil)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_STENCIL, width, height)
+
+	target := &RenderTarget{texture: texture, stencil: stencil}
+	if target.attach() {
+		bindTarget()
+		return target, nil
+	}
+
+	gl.DeleteFramebuffer(target.fbo)
+	gl.DeleteRenderbuffer(stencil)
+	target.stencil = nil
+	complete := target.attach()
+	bindTarget()
+
+	if !complete {
+		target.Delete()
+		return nil, errors.New("engi: render target framebuffer is incomplete")
+	}
+	return target, ErrNoStencil
+}
+
+// attach creates the target's framebuffer from its texture and stencil
+// buffer, if it has one, and reports whether it is complete.
+func (r *RenderTarget) attach() bool {
+	r.fbo = gl.CreateFramebuffer()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, r.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, r.texture.id, 0)
+	if r.stencil != nil {
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, r.stencil)
+	}
+	return gl.CheckFramebufferStatus(gl.FRAMEBUFFER) == gl.FRAMEBUFFER_COMPLETE
+}
+
+// Begin redirects all drawing into the target until End is called. Targets
+// may be nested; End restores whichever was bound before.
+func (r *RenderTarget) Begin() {
+	targets = append(targets, r)
+	bindTarget()
+}
+
+func (r *RenderTarget) End() {
+	n := len(targets)
+	if n == 0 || targets[n-1] != r {
+		panic("RenderTarget.End() called out of order")
+	}
+	targets = targets[:n-1]
+	bindTarget()
+}
+
+// Clear fills the target with color and transparency.
+func (r *RenderTarget) Clear(color uint32, transparency float32) {
+	r.Begin()
+	red := float32((color>>16)&0xFF) / 255.0
+	green := float32((color>>8)&0xFF) / 255.0
+	blue := float32(color&0xFF) / 255.0
+	gl.ClearColor(red, green, blue, transparency)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.ClearColor(bgRed, bgGreen, bgBlue, 1.0)
+	r.End()
+}
+
+// ClearTo fills the target with c.
+func (r *RenderTarget) ClearTo(c Color) {
+	r.Clear(c.Hex(), c.A)
+}
+
+// Delete frees the target's framebuffer and texture.
+func (r *RenderTarget) Delete() {
+	for _, t := range targets {
+		if t == r {
+			panic("RenderTarget.Delete() called while bound")
+		}
+	}
+	gl.DeleteFramebuffer(r.fbo)
+	if r.stencil != nil {
+		gl.DeleteRenderbuffer(r.stencil)
+	}
+	r.texture.Delete()
+}
+
+// ColorBuffer returns the texture the target renders into.
+func (r *RenderTarget) ColorBuffer() *Texture {
+	return r.texture
+}
+
+func (r *RenderTarget) Width() float32 {
+	return r.texture.Width()
+}
+
+func (r *RenderTarget) Height() float32 {
+	return r.texture.Height()
+}
+
+func (r *RenderTarget) Texture() *webgl.Texture {
+	return r.texture.id
+}
+
+// View is flipped vertically, as framebuffer rows are stored bottom up.
+func (r *RenderTarget) View() (float32, float32, float32, float32) {
+	return 0.0, 1.0, 1.0, 0.0
+}
+
+// bindTarget binds the innermost render target, or the default framebuffer
+// if there is none, and sets the viewport to match.
+func bindTarget() {
+	if n := len(targets); n > 0 {
+		t := targets[n-1]
+		gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+		gl.Viewport(0, 0, t.texture.width, t.texture.height)
+		return
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, nil)
+	gl.Viewport(0, 0, viewportWidth, viewportHeight)
+}
@@ -0,0 +1,211 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// decodeTGA decodes a Truevision TGA image: color-mapped, true-color or
+// grayscale, raw or run-length encoded. TGA files have no signature for
+// image.Decode to recognise, so they are picked out by extension.
+func decodeTGA(data []byte) (*image.NRGBA, error) {
+	if len(data) < 18 {
+		return nil, errors.New("tga: truncated header")
+	}
+
+	idLength := int(data[0])
+	mapType := data[1]
+	imageType := data[2]
+	mapFirst := int(le16(data[3:]))
+	mapLength := int(le16(data[5:]))
+	mapDepth := int(data[7])
+	width := int(le16(data[12:]))
+	height := int(le16(data[14:]))
+	depth := int(data[16])
+	descriptor := data[17]
+	alpha := descriptor&0x0F != 0
+
+	rle := imageType&8 != 0
+	imageType &^= 8
+	if imageType < 1 || imageType > 3 {
+		return nil, fmt.Errorf("tga: unsupported image type %d", data[2])
+	}
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("tga: invalid size %dx%d", width, height)
+	}
+
+	pos := 18 + idLength
+	var palette []color.NRGBA
+	if mapType == 1 {
+		size := (mapDepth + 7) / 8
+		if pos+mapLength*size > len(data) {
+			return nil, errors.New("tga: truncated color map")
+		}
+		palette = make([]color.NRGBA, mapLength)
+		for i := range palette {
+			c, err := tgaColor(data[pos+i*size:], mapDepth, alpha)
+			if err != nil {
+				return nil, err
+			}
+			palette[i] = c
+		}
+		pos += mapLength * size
+	}
+
+	var pixel func([]byte) (color.NRGBA, error)
+	switch imageType {
+	case 1:
+		if palette == nil || (depth != 8 && depth != 16) {
+			return nil, errors.New("tga: invalid color-mapped image")
+		}
+		pixel = func(p []byte) (color.NRGBA, error) {
+			i := int(p[0])
+			if depth == 16 {
+				i = int(le16(p))
+			}
+			i -= mapFirst
+			if i < 0 || i >= len(palette) {
+				return color.NRGBA{}, fmt.Errorf("tga: color index %d out of range", i+mapFirst)
+			}
+			return palette[i], nil
+		}
+	case 2:
+		pixel = func(p []byte) (color.NRGBA, error) {
+			return tgaColor(p, depth, alpha)
+		}
+	case 3:
+		if depth != 8 && depth != 16 {
+			return nil, fmt.Errorf("tga: unsupported grayscale depth %d", depth)
+		}
+		pixel = func(p []byte) (color.NRGBA, error) {
+			if depth == 16 {
+				return color.NRGBA{p[0], p[0], p[0], p[1]}, nil
+			}
+			return color.NRGBA{p[0], p[0], p[0], 0xFF}, nil
+		}
+	}
+
+	// Check the data can fill the image before allocating it, as the header
+	// alone can ask for gigabytes. An RLE packet covers at most 128 pixels.
+	size := (depth + 7) / 8
+	need := width * height * size
+	if rle {
+		need = (width*height + 127) / 128 * (1 + size)
+	}
+	if len(data)-pos < need {
+		return nil, errors.New("tga: truncated pixel data")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	set := func(i int, c color.NRGBA) {
+		x, y := i%width, i/width
+		if descriptor&0x10 != 0 {
+			x = width - 1 - x
+		}
+		if descriptor&0x20 == 0 {
+			y = height - 1 - y
+		}
+		img.SetNRGBA(x, y, c)
+	}
+
+	next := func() (color.NRGBA, error) {
+		if pos+size > len(data) {
+			return color.NRGBA{}, errors.New("tga: truncated pixel data")
+		}
+		c, err := pixel(data[pos : pos+size])
+		pos += size
+		return c, err
+	}
+
+	for i, n := 0, width*height; i < n; {
+		count, repeat := 1, false
+		if rle {
+			if pos >= len(data) {
+				return nil, errors.New("tga: truncated pixel data")
+			}
+			count = int(data[pos]&0x7F) + 1
+			repeat = data[pos]&0x80 != 0
+			pos++
+			if i+count > n {
+				return nil, errors.New("tga: run crosses the end of the image")
+			}
+		}
+
+		if repeat {
+			c, err := next()
+			if err != nil {
+				return nil, err
+			}
+			for ; count > 0; count-- {
+				set(i, c)
+				i++
+			}
+			continue
+		}
+
+		for ; count > 0; count-- {
+			c, err := next()
+			if err != nil {
+				return nil, err
+			}
+			set(i, c)
+			i++
+		}
+	}
+
+	return img, nil
+}
+
+// tgaColor reads a single true-color value of the given depth in bits. Alpha
+// is only used if the image descriptor says it has any.
+func tgaColor(p []byte, depth int, alpha bool) (color.NRGBA, error) {
+	switch depth {
+	case 15, 16:
+		if len(p) < 2 {
+			break
+		}
+		v := le16(p)
+		c := color.NRGBA{
+			expand5(v >> 10),
+			expand5(v >> 5),
+			expand5(v),
+			0xFF,
+		}
+		if depth == 16 && alpha && v&0x8000 == 0 {
+			c.A = 0
+		}
+		return c, nil
+	case 24:
+		if len(p) < 3 {
+			break
+		}
+		return color.NRGBA{p[2], p[1], p[0], 0xFF}, nil
+	case 32:
+		if len(p) < 4 {
+			break
+		}
+		c := color.NRGBA{p[2], p[1], p[0], p[3]}
+		if !alpha {
+			c.A = 0xFF
+		}
+		return c, nil
+	default:
+		return color.NRGBA{}, fmt.Errorf("tga: unsupported pixel depth %d", depth)
+	}
+	return color.NRGBA{}, errors.New("tga: truncated pixel data")
+}
+
+func expand5(v uint16) uint8 {
+	v &= 0x1F
+	return uint8(v<<3 | v>>2)
+}
+
+func le16(p []byte) uint16 {
+	return uint16(p[0]) | uint16(p[1])<<8
+}
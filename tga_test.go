@@ -0,0 +1,159 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"image/color"
+	"testing"
+)
+
+// tgaHeader builds the 18 byte header of a TGA file.
+func tgaHeader(mapType, imageType byte, mapLength, mapDepth, width, height int, depth, descriptor byte) []byte {
+	return []byte{
+		0, mapType, imageType,
+		0, 0, byte(mapLength), byte(mapLength >> 8), byte(mapDepth),
+		0, 0, 0, 0,
+		byte(width), byte(width >> 8), byte(height), byte(height >> 8),
+		depth, descriptor,
+	}
+}
+
+func tgaFile(header []byte, body ...byte) []byte {
+	return append(header, body...)
+}
+
+var (
+	tgaRed   = color.NRGBA{0xFF, 0, 0, 0xFF}
+	tgaGreen = color.NRGBA{0, 0xFF, 0, 0xFF}
+	tgaBlue  = color.NRGBA{0, 0, 0xFF, 0xFF}
+	tgaWhite = color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+func TestDecodeTGA(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		// want lists the pixels of a 2x2 image from the top left, row by row.
+		want [4]color.NRGBA
+	}{
+		{
+			"true-color top-left origin",
+			tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 24, 0x20),
+				0, 0, 0xFF, 0, 0xFF, 0,
+				0xFF, 0, 0, 0xFF, 0xFF, 0xFF),
+			[4]color.NRGBA{tgaRed, tgaGreen, tgaBlue, tgaWhite},
+		},
+		{
+			"true-color bottom-left origin",
+			tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 24, 0),
+				0, 0, 0xFF, 0, 0xFF, 0,
+				0xFF, 0, 0, 0xFF, 0xFF, 0xFF),
+			[4]color.NRGBA{tgaBlue, tgaWhite, tgaRed, tgaGreen},
+		},
+		{
+			"true-color right-to-left",
+			tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 24, 0x30),
+				0, 0, 0xFF, 0, 0xFF, 0,
+				0xFF, 0, 0, 0xFF, 0xFF, 0xFF),
+			[4]color.NRGBA{tgaGreen, tgaRed, tgaWhite, tgaBlue},
+		},
+		{
+			"32 bit with alpha",
+			tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 32, 0x28),
+				0, 0, 0xFF, 0x80, 0, 0xFF, 0, 0xFF,
+				0xFF, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0),
+			[4]color.NRGBA{{0xFF, 0, 0, 0x80}, tgaGreen, tgaBlue, {0xFF, 0xFF, 0xFF, 0}},
+		},
+		{
+			"32 bit without alpha bits",
+			tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 32, 0x20),
+				0, 0, 0xFF, 0x80, 0, 0xFF, 0, 0xFF,
+				0xFF, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0),
+			[4]color.NRGBA{tgaRed, tgaGreen, tgaBlue, tgaWhite},
+		},
+		{
+			"16 bit",
+			tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 16, 0x21),
+				0x00, 0xFC, 0xE0, 0x83,
+				0x1F, 0x80, 0xFF, 0x7F),
+			[4]color.NRGBA{tgaRed, tgaGreen, tgaBlue, {0xFF, 0xFF, 0xFF, 0}},
+		},
+		{
+			"color-mapped",
+			tgaFile(tgaHeader(1, 1, 3, 24, 2, 2, 8, 0x20),
+				0, 0, 0xFF, 0, 0xFF, 0, 0xFF, 0, 0,
+				0, 1, 2, 0),
+			[4]color.NRGBA{tgaRed, tgaGreen, tgaBlue, tgaRed},
+		},
+		{
+			"grayscale",
+			tgaFile(tgaHeader(0, 3, 0, 0, 2, 2, 8, 0x20), 0, 0x40, 0x80, 0xFF),
+			[4]color.NRGBA{{0, 0, 0, 0xFF}, {0x40, 0x40, 0x40, 0xFF}, {0x80, 0x80, 0x80, 0xFF}, tgaWhite},
+		},
+		{
+			"run-length encoded",
+			tgaFile(tgaHeader(0, 10, 0, 0, 2, 2, 24, 0x20),
+				0x82, 0, 0, 0xFF,
+				0x00, 0xFF, 0, 0),
+			[4]color.NRGBA{tgaRed, tgaRed, tgaRed, tgaBlue},
+		},
+		{
+			"run-length raw packets",
+			tgaFile(tgaHeader(0, 10, 0, 0, 2, 2, 24, 0x20),
+				0x01, 0, 0, 0xFF, 0, 0xFF, 0,
+				0x81, 0xFF, 0xFF, 0xFF),
+			[4]color.NRGBA{tgaRed, tgaGreen, tgaWhite, tgaWhite},
+		},
+		{
+			"run-length color-mapped bottom-left origin",
+			tgaFile(tgaHeader(1, 9, 2, 24, 2, 2, 8, 0),
+				0, 0, 0xFF, 0xFF, 0, 0,
+				0x81, 0, 0x81, 1),
+			[4]color.NRGBA{tgaBlue, tgaBlue, tgaRed, tgaRed},
+		},
+	}
+
+	for _, tt := range tests {
+		img, err := decodeTGA(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+			t.Errorf("%s: size is %v, want 2x2", tt.name, b.Size())
+			continue
+		}
+		for i, want := range tt.want {
+			if got := img.NRGBAAt(i%2, i/2); got != want {
+				t.Errorf("%s: pixel %d, %d = %v, want %v", tt.name, i%2, i/2, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeTGAErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"short header", make([]byte, 10)},
+		{"unsupported type", tgaHeader(0, 5, 0, 0, 2, 2, 24, 0)},
+		{"zero size", tgaHeader(0, 2, 0, 0, 0, 2, 24, 0)},
+		{"truncated pixels", tgaFile(tgaHeader(0, 2, 0, 0, 2, 2, 24, 0), 0, 0, 0xFF)},
+		{"truncated color map", tgaFile(tgaHeader(1, 1, 4, 24, 2, 2, 8, 0), 0, 0, 0xFF)},
+		{"missing color map", tgaFile(tgaHeader(0, 1, 0, 0, 1, 1, 8, 0), 0)},
+		{"index out of range", tgaFile(tgaHeader(1, 1, 1, 24, 1, 1, 8, 0), 0, 0, 0xFF, 5)},
+		{"run past end", tgaFile(tgaHeader(0, 10, 0, 0, 1, 1, 24, 0), 0x83, 0, 0, 0xFF)},
+		{"unsupported depth", tgaFile(tgaHeader(0, 2, 0, 0, 1, 1, 8, 0), 0)},
+		{"oversized", tgaHeader(0, 2, 0, 0, 65535, 65535, 32, 0)},
+		{"oversized run-length", tgaFile(tgaHeader(0, 10, 0, 0, 65535, 65535, 32, 0), 0xFF, 0, 0, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeTGA(tt.data); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+// DrawTiled repeats r at its natural size to fill the rectangle x, y, w, h.
+// The pattern is shifted by offsetU, offsetV in fractions of r's size, so
+// increasing them over time scrolls it. Atlas regions are tiled too, by
+// cropping quads rather than relying on texture wrapping.
+func (b *Batch) DrawTiled(r Drawable, x, y, w, h, offsetU, offsetV float32) {
+	rw, rh := r.Width(), r.Height()
+	b.tileSub(r, 0, 0, rw, rh, rw, rh, x, y, w, h, offsetU*rw, offsetV*rh, packColor(0xffffff, 1))
+}
+
+// ParallaxLayer is a repeating background that scrolls at a fraction of the
+// camera's speed. Layers with smaller factors appear further away.
+type ParallaxLayer struct {
+	Drawable         Drawable
+	FactorX, FactorY float32
+	OffsetX, OffsetY float32
+	RepeatX, RepeatY bool
+	Color            uint32
+	Alpha            float32
+}
+
+// NewParallaxLayer creates a layer repeating on both axes.
+func NewParallaxLayer(d Drawable, factorX, factorY float32) *ParallaxLayer {
+	return &ParallaxLayer{
+		Drawable: d,
+		FactorX:  factorX,
+		FactorY:  factorY,
+		RepeatX:  true,
+		RepeatY:  true,
+		Color:    0xffffff,
+		Alpha:    1,
+	}
+}
+
+// Draw fills the rectangle x, y, w, h with the layer as seen from a camera
+// at cameraX, cameraY. On an axis that doesn't repeat, the drawable is drawn
+// once, scrolling out of the rectangle.
+func (p *ParallaxLayer) Draw(batch *Batch, x, y, w, h, cameraX, cameraY float32) {
+	d := p.Drawable
+	dw, dh := d.Width(), d.Height()
+	scrollX := cameraX*p.FactorX - p.OffsetX
+	scrollY := cameraY*p.FactorY - p.OffsetY
+	tint := packColor(p.Color, p.Alpha)
+
+	areaX, areaW, offsetX := x, w, scrollX
+	if !p.RepeatX {
+		areaX, areaW, offsetX = x-scrollX, dw, 0
+	}
+	areaY, areaH, offsetY := y, h, scrollY
+	if !p.RepeatY {
+		areaY, areaH, offsetY = y-scrollY, dh, 0
+	}
+
+	batch.tileSub(d, 0, 0, dw, dh, dw, dh, areaX, areaY, areaW, areaH, offsetX, offsetY, tint)
+}
+
+// SetTint sets the layer's Color and Alpha from c.
+func (p *ParallaxLayer) SetTint(c Color) {
+	p.Color = c.Hex()
+	p.Alpha = c.A
+}
+
+// Tint returns the layer's Color and Alpha as a Color.
+func (p *ParallaxLayer) Tint() Color {
+	return Hex(p.Color).WithAlpha(p.Alpha)
+}
+
+// drawSub stretches the source rectangle sx, sy, sw, sh of r, in pixels, over
+// the destination rectangle dx, dy, dw, dh.
+func (b *Batch) drawSub(r Drawable, sx, sy, sw, sh, dx, dy, dw, dh, tint float32) {
+	if dw <= 0 || dh <= 0 || sw <= 0 || sh <= 0 {
+		return
+	}
+	u, v, u2, v2 := r.View()
+	du := (u2 - u) / r.Width()
+	dv := (v2 - v) / r.Height()
+	su, sv := u+sx*du, v+sy*dv
+	su2, sv2 := u+(sx+sw)*du, v+(sy+sh)*dv
+	b.quad(r.Texture(), &[20]float32{
+		dx, dy, su, sv, tint,
+		dx + dw, dy, su2, sv, tint,
+		dx + dw, dy + dh, su2, sv2, tint,
+		dx, dy + dh, su, sv2, tint,
+	})
+}
+
+// tileSub repeats the source rectangle sx, sy, sw, sh of r, in pixels, as
+// tiles of tw by th pixels across the destination rectangle dx, dy, dw, dh.
+// The pattern is shifted by offsetX, offsetY pixels, and tiles at the edges
+// are cropped, so it works on atlas regions where texture wrapping can't be
+// used.
+func (b *Batch) tileSub(r Drawable, sx, sy, sw, sh, tw, th, dx, dy, dw, dh, offsetX, offsetY, tint float32) {
+	if dw <= 0 || dh <= 0 || sw <= 0 || sh <= 0 || tw <= 0 || th <= 0 {
+		return
+	}
+
+	scaleX := sw / tw
+	scaleY := sh / th
+	offsetX = mod(offsetX, tw)
+	offsetY = mod(offsetY, th)
+
+	for y := -offsetY; y < dh; y += th {
+		top := y
+		if top < 0 {
+			top = 0
+		}
+		bottom := y + th
+		if bottom > dh {
+			bottom = dh
+		}
+		for x := -offsetX; x < dw; x += tw {
+			left := x
+			if left < 0 {
+				left = 0
+			}
+			right := x + tw
+			if right > dw {
+				right = dw
+			}
+			b.drawSub(r,
+				sx+(left-x)*scaleX, sy+(top-y)*scaleY, (right-left)*scaleX, (bottom-top)*scaleY,
+				dx+left, dy+top, right-left, bottom-top, tint)
+		}
+	}
+}
+
+// mod returns x modulo m, always in [0, m).
+func mod(x, m float32) float32 {
+	x -= float32(int(x/m)) * m
+	if x < 0 {
+		x += m
+	}
+	return x
+}
@@ -0,0 +1,88 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"errors"
+	"fmt"
+
+	"engo.io/audio"
+)
+
+// decodeWAV reads the format of an uncompressed 8 or 16 bit, mono or stereo
+// RIFF WAVE file and returns its samples, which are left in place.
+func decodeWAV(data []byte) (*pcm, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("wav: missing RIFF WAVE header")
+	}
+
+	var (
+		format   audio.Format
+		rate     int64
+		haveFmt  bool
+		pos      = 12
+		chunkEnd int
+	)
+	for ; pos+8 <= len(data); pos = chunkEnd + chunkEnd&1 {
+		id := string(data[pos : pos+4])
+		size := int(le32(data[pos+4:]))
+		pos += 8
+		chunkEnd = pos + size
+		if size < 0 || chunkEnd > len(data) {
+			if id != "data" {
+				return nil, fmt.Errorf("wav: truncated %q chunk", id)
+			}
+			// Streaming encoders often leave the data size unset.
+			chunkEnd = len(data)
+		}
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("wav: truncated format chunk")
+			}
+			tag := le16(data[pos:])
+			channels := int(le16(data[pos+2:]))
+			bits := int(le16(data[pos+14:]))
+			if tag == 0xFFFE && size >= 26 {
+				// WAVE_FORMAT_EXTENSIBLE keeps the real tag in its sub-format.
+				tag = le16(data[pos+24:])
+			}
+			if tag != 1 {
+				return nil, fmt.Errorf("wav: unsupported encoding %d, only PCM is supported", tag)
+			}
+			switch {
+			case channels == 1 && bits == 8:
+				format = audio.Mono8
+			case channels == 1 && bits == 16:
+				format = audio.Mono16
+			case channels == 2 && bits == 8:
+				format = audio.Stereo8
+			case channels == 2 && bits == 16:
+				format = audio.Stereo16
+			default:
+				return nil, fmt.Errorf("wav: unsupported format, %d channels of %d bits", channels, bits)
+			}
+			rate = int64(le32(data[pos+4:]))
+			if rate == 0 {
+				return nil, errors.New("wav: invalid sample rate 0")
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, errors.New("wav: data chunk before format chunk")
+			}
+			return &pcm{format, rate, data[pos:chunkEnd]}, nil
+		}
+	}
+	if !haveFmt {
+		return nil, errors.New("wav: missing format chunk")
+	}
+	return nil, errors.New("wav: missing data chunk")
+}
+
+func le32(p []byte) uint32 {
+	return uint32(p[0]) | uint32(p[1])<<8 | uint32(p[2])<<16 | uint32(p[3])<<24
+}
@@ -0,0 +1,110 @@
+// Copyright 2014-2016 Joseph Hager. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engi
+
+import (
+	"bytes"
+	"testing"
+
+	"engo.io/audio"
+)
+
+// wavChunk builds a RIFF chunk, padded to an even length.
+func wavChunk(id string, body []byte) []byte {
+	n := len(body)
+	chunk := append([]byte(id), byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	chunk = append(chunk, body...)
+	if n&1 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// wavFmt builds the body of a format chunk.
+func wavFmt(tag, channels, rate, bits int) []byte {
+	align := channels * bits / 8
+	b := []byte{byte(tag), byte(tag >> 8), byte(channels), 0}
+	for _, v := range []int{rate, rate * align} {
+		b = append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	return append(b, byte(align), 0, byte(bits), 0)
+}
+
+func wavFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return wavChunk("RIFF", body)
+}
+
+func TestLoadWAV(t *testing.T) {
+	samples := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	fs := NewFS()
+	fs.Mount("", MapSource{"sfx/hit.wav": wavFile(
+		wavChunk("fmt ", wavFmt(1, 2, 22050, 16)),
+		wavChunk("LIST", []byte("INFOjunk!")),
+		wavChunk("data", samples),
+	)})
+
+	value, _, err := read(fs, Resource{name: "hit", url: "sfx/hit.wav", kind: "sound"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sound, ok := value.(*pcm)
+	if !ok {
+		t.Fatalf("read returned %T, want *pcm", value)
+	}
+	if sound.format != audio.Stereo16 || sound.rate != 22050 {
+		t.Errorf("format %v at %d Hz, want %v at 22050 Hz", sound.format, sound.rate, audio.Stereo16)
+	}
+	if !bytes.Equal(sound.data, samples) {
+		t.Errorf("samples %v, want %v", sound.data, samples)
+	}
+}
+
+func TestDecodeWAV(t *testing.T) {
+	samples := []byte{0x80, 0x90, 0xA0}
+	tests := []struct {
+		name   string
+		data   []byte
+		format audio.Format
+		rate   int64
+		pcm    []byte
+		err    string
+	}{
+		{"mono 8", wavFile(wavChunk("fmt ", wavFmt(1, 1, 8000, 8)), wavChunk("data", samples)), audio.Mono8, 8000, samples, ""},
+		{"mono 16", wavFile(wavChunk("fmt ", wavFmt(1, 1, 44100, 16)), wavChunk("data", samples[:2])), audio.Mono16, 44100, samples[:2], ""},
+		{"stereo 8", wavFile(wavChunk("fmt ", wavFmt(1, 2, 11025, 8)), wavChunk("data", samples[:2])), audio.Stereo8, 11025, samples[:2], ""},
+		{"unset data size", append(wavFile(wavChunk("fmt ", wavFmt(1, 1, 8000, 8))), 'd', 'a', 't', 'a', 0xFF, 0xFF, 0xFF, 0xFF, 1, 2), audio.Mono8, 8000, []byte{1, 2}, ""},
+		{"not riff", []byte("RIFX\x00\x00\x00\x00WAVE"), 0, 0, nil, "wav: missing RIFF WAVE header"},
+		{"short", []byte("RIFF"), 0, 0, nil, "wav: missing RIFF WAVE header"},
+		{"no format", wavFile(wavChunk("data", samples)), 0, 0, nil, "wav: data chunk before format chunk"},
+		{"no data", wavFile(wavChunk("fmt ", wavFmt(1, 1, 8000, 8))), 0, 0, nil, "wav: missing data chunk"},
+		{"empty", wavFile(), 0, 0, nil, "wav: missing format chunk"},
+		{"short format", wavFile(wavChunk("fmt ", wavFmt(1, 1, 8000, 8)[:12])), 0, 0, nil, "wav: truncated format chunk"},
+		{"truncated chunk", wavFile(wavChunk("fmt ", wavFmt(1, 1, 8000, 8)))[:30], 0, 0, nil, `wav: truncated "fmt " chunk`},
+		{"float", wavFile(wavChunk("fmt ", wavFmt(3, 1, 8000, 32)), wavChunk("data", samples)), 0, 0, nil, "wav: unsupported encoding 3, only PCM is supported"},
+		{"surround", wavFile(wavChunk("fmt ", wavFmt(1, 6, 8000, 16)), wavChunk("data", samples)), 0, 0, nil, "wav: unsupported format, 6 channels of 16 bits"},
+		{"24 bit", wavFile(wavChunk("fmt ", wavFmt(1, 2, 8000, 24)), wavChunk("data", samples)), 0, 0, nil, "wav: unsupported format, 2 channels of 24 bits"},
+		{"zero rate", wavFile(wavChunk("fmt ", wavFmt(1, 1, 0, 8)), wavChunk("data", samples)), 0, 0, nil, "wav: invalid sample rate 0"},
+	}
+	for _, test := range tests {
+		sound, err := decodeWAV(test.data)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if sound.format != test.format || sound.rate != test.rate || !bytes.Equal(sound.data, test.pcm) {
+			t.Errorf("%s: got %v at %d Hz %v, want %v at %d Hz %v", test.name, sound.format, sound.rate, sound.data, test.format, test.rate, test.pcm)
+		}
+	}
+}